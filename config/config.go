@@ -0,0 +1,238 @@
+// Package config loads the services' settings. Each setting is taken from,
+// in increasing order of precedence:
+//
+//  1. the built-in defaults
+//  2. a YAML (.yaml, .yml) or TOML (.toml) file named by -config or CONFIG_FILE
+//  3. environment variables
+//  4. command-line flags
+//
+// The result is validated before it is returned, so a service either starts
+// with a usable configuration or not at all. Run a service with -help to
+// list the flags, and see Settings for every environment variable.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the full set of settings
+type Config struct {
+	Environment string `yaml:"environment" toml:"environment"` // development or production
+	ListenAddr  string `yaml:"listen_addr" toml:"listen_addr"`
+	LogLevel    string `yaml:"log_level" toml:"log_level"` // debug, info, warn or error
+
+	Shutdown  ShutdownConfig  `yaml:"shutdown" toml:"shutdown"`
+	Storage   StorageConfig   `yaml:"storage" toml:"storage"`
+	Secrets   SecretsConfig   `yaml:"secrets" toml:"secrets"`
+	CORS      CORSConfig      `yaml:"cors" toml:"cors"`
+	RateLimit RateLimitConfig `yaml:"rate_limit" toml:"rate_limit"`
+}
+
+// ShutdownConfig controls graceful shutdown
+type ShutdownConfig struct {
+	Delay   Duration `yaml:"delay" toml:"delay"`     // How long readiness fails before the server stops
+	Timeout Duration `yaml:"timeout" toml:"timeout"` // How long in-flight requests then get to finish
+}
+
+// StorageConfig says where data is kept
+type StorageConfig struct {
+	DSN       string `yaml:"dsn" toml:"dsn"`               // Only memory:// is supported for now
+	UploadDir string `yaml:"upload_dir" toml:"upload_dir"` // Blob store directory; empty means a temp dir
+}
+
+// SecretsConfig holds credentials. Prefer setting these through the
+// environment rather than a file checked in anywhere.
+type SecretsConfig struct {
+	TokenSecret Secret `yaml:"token_secret" toml:"token_secret"` // Signs auth tokens; required in production
+}
+
+// CORSConfig controls which browser origins may call the APIs
+type CORSConfig struct {
+	AllowedOrigins   []string `yaml:"allowed_origins" toml:"allowed_origins"` // "*" for any origin
+	AllowedMethods   []string `yaml:"allowed_methods" toml:"allowed_methods"`
+	AllowedHeaders   []string `yaml:"allowed_headers" toml:"allowed_headers"`
+	ExposedHeaders   []string `yaml:"exposed_headers" toml:"exposed_headers"`
+	AllowCredentials bool     `yaml:"allow_credentials" toml:"allow_credentials"`
+	MaxAge           Duration `yaml:"max_age" toml:"max_age"` // How long browsers may cache a preflight
+}
+
+// RateLimitConfig limits how fast one client may make requests
+type RateLimitConfig struct {
+	Enabled           bool `yaml:"enabled" toml:"enabled"`
+	RequestsPerMinute int  `yaml:"requests_per_minute" toml:"requests_per_minute"`
+	Burst             int  `yaml:"burst" toml:"burst"` // Requests allowed at once before limiting starts
+}
+
+// Default returns the built-in settings, which suit local development
+func Default() Config {
+	return Config{
+		Environment: "development",
+		ListenAddr:  ":8080",
+		LogLevel:    "info",
+		Shutdown: ShutdownConfig{
+			Delay:   Duration(5 * time.Second),
+			Timeout: Duration(10 * time.Second),
+		},
+		Storage: StorageConfig{
+			DSN: "memory://",
+		},
+		CORS: CORSConfig{
+			AllowedOrigins: []string{"*"},
+			AllowedMethods: []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+			AllowedHeaders: []string{"Content-Type", "Authorization", "If-Match", "X-Request-ID", "traceparent"},
+			ExposedHeaders: []string{"ETag", "X-Request-ID"},
+			MaxAge:         Duration(10 * time.Minute),
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:           false,
+			RequestsPerMinute: 600,
+			Burst:             100,
+		},
+	}
+}
+
+// Load reads the configuration for a service. args are the command-line
+// arguments without the program name; pass nil when the service has no
+// command line. Flags that aren't settings are rejected.
+func Load(args []string) (Config, error) {
+	cfg := Default()
+
+	// Flags are parsed first to find the config file, but applied last
+	flags := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	configFile := flags.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML config file (env CONFIG_FILE)")
+	values := map[string]*string{}
+	for _, s := range Settings {
+		if s.Flag != "" {
+			values[s.Flag] = flags.String(s.Flag, "", s.Usage+" (env "+s.Env+")")
+		}
+	}
+	err := flags.Parse(args)
+	if err != nil {
+		return cfg, err
+	}
+	if flags.NArg() > 0 {
+		return cfg, fmt.Errorf("unexpected argument %q", flags.Arg(0))
+	}
+
+	if *configFile != "" {
+		err = loadFile(&cfg, *configFile)
+		if err != nil {
+			return cfg, err
+		}
+	}
+
+	for _, s := range Settings {
+		value, ok := os.LookupEnv(s.Env)
+		if !ok {
+			continue
+		}
+		err = s.set(&cfg, value)
+		if err != nil {
+			return cfg, fmt.Errorf("%s: %w", s.Env, err)
+		}
+	}
+
+	flags.Visit(func(f *flag.Flag) {
+		if err != nil || f.Name == "config" {
+			return
+		}
+		for _, s := range Settings {
+			if s.Flag == f.Name {
+				err = s.set(&cfg, *values[f.Name])
+				if err != nil {
+					err = fmt.Errorf("-%s: %w", f.Name, err)
+				}
+				return
+			}
+		}
+	})
+	if err != nil {
+		return cfg, err
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// loadFile merges a YAML or TOML file over cfg. Unknown keys are errors,
+// so a misspelt setting doesn't silently keep its default.
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(cfg)
+		if errors.Is(err, io.EOF) {
+			err = nil // An empty file changes nothing
+		}
+	case ".toml":
+		decoder := toml.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(cfg)
+	default:
+		return fmt.Errorf("config file %s: want a .yaml, .yml or .toml file", path)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Duration is a time.Duration written as a string such as "30s" or "5m"
+type Duration time.Duration
+
+// UnmarshalText parses a duration for YAML, TOML and the environment
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalText writes a duration the way it is read
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Std returns the duration as a time.Duration
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
+
+// Secret is a string kept out of logs and printed configs
+type Secret string
+
+// String hides the value
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return "[REDACTED]"
+}
+
+// MarshalText hides the value when a config is encoded
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Reveal returns the actual value, for the code that needs it
+func (s Secret) Reveal() string {
+	return string(s)
+}
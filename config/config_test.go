@@ -0,0 +1,150 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	yamlFile := filepath.Join(dir, "app.yaml")
+	tomlFile := filepath.Join(dir, "app.toml")
+	os.WriteFile(yamlFile, []byte("listen_addr: \":9000\"\nlog_level: debug\nshutdown:\n  delay: 1s\n"), 0o600)
+	os.WriteFile(tomlFile, []byte("listen_addr = \":9001\"\n[rate_limit]\nenabled = true\nburst = 5\n"), 0o600)
+
+	tests := []struct {
+		name  string
+		env   map[string]string
+		args  []string
+		check func(t *testing.T, cfg Config)
+	}{
+		{
+			name: "defaults",
+			check: func(t *testing.T, cfg Config) {
+				want := Default()
+				if cfg.ListenAddr != want.ListenAddr || cfg.LogLevel != want.LogLevel || cfg.Shutdown != want.Shutdown {
+					t.Errorf("got %+v, want the defaults", cfg)
+				}
+			},
+		},
+		{
+			name: "yaml file over defaults",
+			args: []string{"-config", yamlFile},
+			check: func(t *testing.T, cfg Config) {
+				if cfg.ListenAddr != ":9000" || cfg.LogLevel != "debug" || cfg.Shutdown.Delay.Std() != time.Second {
+					t.Errorf("file not applied: %+v", cfg)
+				}
+				if cfg.Shutdown.Timeout != Default().Shutdown.Timeout {
+					t.Errorf("unset key changed the default: timeout %v", cfg.Shutdown.Timeout.Std())
+				}
+			},
+		},
+		{
+			name: "toml file from CONFIG_FILE",
+			env:  map[string]string{"CONFIG_FILE": tomlFile},
+			check: func(t *testing.T, cfg Config) {
+				if cfg.ListenAddr != ":9001" || !cfg.RateLimit.Enabled || cfg.RateLimit.Burst != 5 {
+					t.Errorf("file not applied: %+v", cfg)
+				}
+				if cfg.RateLimit.RequestsPerMinute != Default().RateLimit.RequestsPerMinute {
+					t.Errorf("unset key changed the default: %d", cfg.RateLimit.RequestsPerMinute)
+				}
+			},
+		},
+		{
+			name: "env over file",
+			env:  map[string]string{"LISTEN_ADDR": ":9100", "CORS_ALLOWED_ORIGINS": "https://a.example, https://b.example"},
+			args: []string{"-config", yamlFile},
+			check: func(t *testing.T, cfg Config) {
+				if cfg.ListenAddr != ":9100" || cfg.LogLevel != "debug" {
+					t.Errorf("env not applied over file: %+v", cfg)
+				}
+				if strings.Join(cfg.CORS.AllowedOrigins, " ") != "https://a.example https://b.example" {
+					t.Errorf("origins = %q", cfg.CORS.AllowedOrigins)
+				}
+			},
+		},
+		{
+			name: "flags over env",
+			env:  map[string]string{"LISTEN_ADDR": ":9100", "LOG_LEVEL": "warn"},
+			args: []string{"-config", yamlFile, "-listen", ":9200"},
+			check: func(t *testing.T, cfg Config) {
+				if cfg.ListenAddr != ":9200" || cfg.LogLevel != "warn" {
+					t.Errorf("flag not applied over env: %+v", cfg)
+				}
+			},
+		},
+		{
+			name: "secret only from env",
+			env:  map[string]string{"APP_ENV": "production", "TOKEN_SECRET": strings.Repeat("s", minSecretLength)},
+			check: func(t *testing.T, cfg Config) {
+				if cfg.Secrets.TokenSecret.Reveal() != strings.Repeat("s", minSecretLength) {
+					t.Error("secret not read from the environment")
+				}
+				if cfg.Secrets.TokenSecret.String() != "[REDACTED]" {
+					t.Errorf("secret printed as %q", cfg.Secrets.TokenSecret.String())
+				}
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, s := range Settings {
+				t.Setenv(s.Env, "")
+				os.Unsetenv(s.Env)
+			}
+			t.Setenv("CONFIG_FILE", "")
+			for key, value := range tc.env {
+				t.Setenv(key, value)
+			}
+			cfg, err := Load(tc.args)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			tc.check(t, cfg)
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	typo := filepath.Join(dir, "typo.yaml")
+	os.WriteFile(typo, []byte("listen_adr: \":9000\"\n"), 0o600)
+	ini := filepath.Join(dir, "app.ini")
+	os.WriteFile(ini, []byte("listen_addr=:9000\n"), 0o600)
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		want string
+	}{
+		{"unknown file key", nil, []string{"-config", typo}, "listen_adr"},
+		{"unknown file type", nil, []string{"-config", ini}, "want a .yaml"},
+		{"bad env value", map[string]string{"RATE_LIMIT_BURST": "lots"}, nil, "RATE_LIMIT_BURST"},
+		{"bad flag value", nil, []string{"-shutdown-delay", "soon"}, "-shutdown-delay"},
+		{"unknown flag", nil, []string{"-verbose"}, "verbose"},
+		{"production needs a secret", map[string]string{"APP_ENV": "production"}, nil, "token_secret is required"},
+		{"credentials need origins", map[string]string{"CORS_ALLOW_CREDENTIALS": "true"}, nil, "explicit origins"},
+		{"invalid values", map[string]string{"LOG_LEVEL": "loud", "STORAGE_DSN": "postgres://db"}, nil, "log_level"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, s := range Settings {
+				t.Setenv(s.Env, "")
+				os.Unsetenv(s.Env)
+			}
+			t.Setenv("CONFIG_FILE", "")
+			for key, value := range tc.env {
+				t.Setenv(key, value)
+			}
+			_, err := Load(tc.args)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("Load error = %v, want one mentioning %q", err, tc.want)
+			}
+		})
+	}
+}
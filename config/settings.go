@@ -0,0 +1,195 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Setting is one value that can come from the environment and, when Flag
+// is set, the command line
+type Setting struct {
+	Env   string
+	Flag  string // Empty for settings that shouldn't appear in ps output
+	Usage string
+	set   func(cfg *Config, value string) error
+}
+
+// Settings lists everything that can be set outside a config file
+var Settings = []Setting{
+	{"APP_ENV", "env", "development or production", func(c *Config, v string) error {
+		c.Environment = v
+		return nil
+	}},
+	{"LISTEN_ADDR", "listen", "address to listen on, e.g. :8080", func(c *Config, v string) error {
+		c.ListenAddr = v
+		return nil
+	}},
+	{"LOG_LEVEL", "log-level", "debug, info, warn or error", func(c *Config, v string) error {
+		c.LogLevel = v
+		return nil
+	}},
+	{"SHUTDOWN_DELAY", "shutdown-delay", "how long readiness fails before shutting down", func(c *Config, v string) error {
+		return c.Shutdown.Delay.UnmarshalText([]byte(v))
+	}},
+	{"SHUTDOWN_TIMEOUT", "shutdown-timeout", "how long in-flight requests get to finish", func(c *Config, v string) error {
+		return c.Shutdown.Timeout.UnmarshalText([]byte(v))
+	}},
+	{"STORAGE_DSN", "storage-dsn", "where data is kept", func(c *Config, v string) error {
+		c.Storage.DSN = v
+		return nil
+	}},
+	{"UPLOAD_DIR", "upload-dir", "directory for uploaded files", func(c *Config, v string) error {
+		c.Storage.UploadDir = v
+		return nil
+	}},
+	{"TOKEN_SECRET", "", "key that signs auth tokens", func(c *Config, v string) error {
+		c.Secrets.TokenSecret = Secret(v)
+		return nil
+	}},
+	{"CORS_ALLOWED_ORIGINS", "cors-origins", "comma-separated origins allowed to call the API, or *", func(c *Config, v string) error {
+		c.CORS.AllowedOrigins = splitList(v)
+		return nil
+	}},
+	{"CORS_ALLOWED_METHODS", "cors-methods", "comma-separated methods allowed cross-origin", func(c *Config, v string) error {
+		c.CORS.AllowedMethods = splitList(v)
+		return nil
+	}},
+	{"CORS_ALLOWED_HEADERS", "cors-headers", "comma-separated request headers allowed cross-origin", func(c *Config, v string) error {
+		c.CORS.AllowedHeaders = splitList(v)
+		return nil
+	}},
+	{"CORS_EXPOSED_HEADERS", "cors-exposed-headers", "comma-separated response headers scripts may read", func(c *Config, v string) error {
+		c.CORS.ExposedHeaders = splitList(v)
+		return nil
+	}},
+	{"CORS_ALLOW_CREDENTIALS", "cors-credentials", "allow cookies and auth headers cross-origin", func(c *Config, v string) error {
+		return parseBool(&c.CORS.AllowCredentials, v)
+	}},
+	{"CORS_MAX_AGE", "cors-max-age", "how long browsers may cache a preflight", func(c *Config, v string) error {
+		return c.CORS.MaxAge.UnmarshalText([]byte(v))
+	}},
+	{"RATE_LIMIT_ENABLED", "rate-limit", "limit requests per client", func(c *Config, v string) error {
+		return parseBool(&c.RateLimit.Enabled, v)
+	}},
+	{"RATE_LIMIT_RPM", "rate-limit-rpm", "requests per minute allowed per client", func(c *Config, v string) error {
+		return parseInt(&c.RateLimit.RequestsPerMinute, v)
+	}},
+	{"RATE_LIMIT_BURST", "rate-limit-burst", "requests a client may make at once", func(c *Config, v string) error {
+		return parseInt(&c.RateLimit.Burst, v)
+	}},
+}
+
+// splitList reads a comma-separated list, dropping blanks
+func splitList(value string) []string {
+	list := []string{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func parseBool(dst *bool, value string) error {
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return errors.New("must be true or false")
+	}
+	*dst = parsed
+	return nil
+}
+
+func parseInt(dst *int, value string) error {
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return errors.New("must be a whole number")
+	}
+	*dst = parsed
+	return nil
+}
+
+// minSecretLength is the shortest token secret accepted
+const minSecretLength = 32
+
+// Validate reports every problem with a configuration at once
+func (c Config) Validate() error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if c.Environment != "development" && c.Environment != "production" {
+		add("environment must be development or production, not %q", c.Environment)
+	}
+	_, _, err := net.SplitHostPort(c.ListenAddr)
+	if err != nil {
+		add("listen_addr %q must be host:port or :port", c.ListenAddr)
+	}
+	var level slog.Level
+	err = level.UnmarshalText([]byte(c.LogLevel))
+	if err != nil {
+		add("log_level must be debug, info, warn or error, not %q", c.LogLevel)
+	}
+
+	if c.Shutdown.Delay < 0 || c.Shutdown.Timeout < 0 {
+		add("shutdown delay and timeout cannot be negative")
+	}
+
+	dsn, err := url.Parse(c.Storage.DSN)
+	if err != nil || dsn.Scheme != "memory" {
+		add("storage dsn %q is not supported; only memory:// is available", c.Storage.DSN)
+	}
+
+	secret := c.Secrets.TokenSecret.Reveal()
+	if secret == "" && c.Environment == "production" {
+		add("token_secret is required in production")
+	} else if secret != "" && len(secret) < minSecretLength {
+		add("token_secret must be at least %d characters", minSecretLength)
+	}
+
+	problems = append(problems, c.CORS.problems()...)
+
+	if c.RateLimit.Enabled && (c.RateLimit.RequestsPerMinute <= 0 || c.RateLimit.Burst <= 0) {
+		add("rate_limit requests_per_minute and burst must be positive")
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// problems checks the CORS settings
+func (c CORSConfig) problems() []string {
+	var problems []string
+	if len(c.AllowedOrigins) == 0 {
+		problems = append(problems, "cors allowed_origins cannot be empty; use * for any origin")
+	}
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" {
+			if c.AllowCredentials {
+				problems = append(problems, "cors allow_credentials needs explicit origins, not *")
+			}
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+			problems = append(problems, fmt.Sprintf("cors origin %q must look like https://example.com", origin))
+		}
+	}
+	for _, method := range c.AllowedMethods {
+		if method != strings.ToUpper(method) || strings.ContainsAny(method, " \t") {
+			problems = append(problems, fmt.Sprintf("cors method %q must be an upper-case HTTP method", method))
+		}
+	}
+	if c.MaxAge < 0 {
+		problems = append(problems, "cors max_age cannot be negative")
+	}
+	return problems
+}
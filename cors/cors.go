@@ -0,0 +1,124 @@
+// Package cors answers cross-origin requests for both services, following
+// the settings in config.CORSConfig. Each service knows which methods a
+// route accepts, so it passes them in; the policy decides what a given
+// origin may see.
+package cors
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"learn_go/config"
+)
+
+// Policy is a compiled CORS configuration
+type Policy struct {
+	anyOrigin   bool
+	origins     map[string]bool
+	methods     map[string]bool
+	headers     string
+	exposed     string
+	credentials bool
+	maxAge      string
+}
+
+// New compiles a CORS configuration. It should already have been checked
+// by config.Validate.
+func New(cfg config.CORSConfig) *Policy {
+	p := &Policy{
+		origins:     map[string]bool{},
+		methods:     map[string]bool{},
+		headers:     strings.Join(cfg.AllowedHeaders, ", "),
+		exposed:     strings.Join(cfg.ExposedHeaders, ", "),
+		credentials: cfg.AllowCredentials,
+	}
+	for _, origin := range cfg.AllowedOrigins {
+		if origin == "*" {
+			p.anyOrigin = true
+			continue
+		}
+		p.origins[strings.ToLower(strings.TrimSuffix(origin, "/"))] = true
+	}
+	for _, method := range cfg.AllowedMethods {
+		p.methods[method] = true
+	}
+	if seconds := int(cfg.MaxAge.Std().Seconds()); seconds > 0 {
+		p.maxAge = strconv.Itoa(seconds)
+	}
+	return p
+}
+
+// AllowsOrigin reports whether a browser at origin may call the API
+func (p *Policy) AllowsOrigin(origin string) bool {
+	return p.anyOrigin || p.origins[strings.ToLower(origin)]
+}
+
+// Handle adds the CORS headers for a request to a route that accepts
+// methods, and answers OPTIONS requests, preflight or not. It returns true
+// when the request has been answered.
+//
+// Pass nil methods for a path the service doesn't serve: nothing is added
+// and the caller responds as it would to any unknown path.
+func (p *Policy) Handle(w http.ResponseWriter, r *http.Request, methods []string) bool {
+	if len(methods) == 0 {
+		return false
+	}
+
+	header := w.Header()
+	// Unless any origin gets the same answer, caches must key on Origin
+	if !p.anyOrigin || p.credentials {
+		header.Add("Vary", "Origin")
+	}
+
+	origin := r.Header.Get("Origin")
+	allowed := origin != "" && p.AllowsOrigin(origin)
+	if allowed {
+		if p.anyOrigin && !p.credentials {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+		if p.credentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+	}
+
+	if r.Method != http.MethodOptions {
+		if allowed && p.exposed != "" {
+			header.Set("Access-Control-Expose-Headers", p.exposed)
+		}
+		return false
+	}
+
+	// Plain OPTIONS requests learn what the route accepts
+	header.Set("Allow", strings.Join(append(slices.Clone(methods), http.MethodOptions), ", "))
+
+	// A preflight is only answered in full for an allowed origin; the
+	// browser then checks the requested method and headers against it
+	if allowed && r.Header.Get("Access-Control-Request-Method") != "" {
+		if methods := p.allowedMethods(methods); len(methods) > 0 {
+			header.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+		}
+		if p.headers != "" {
+			header.Set("Access-Control-Allow-Headers", p.headers)
+		}
+		if p.maxAge != "" {
+			header.Set("Access-Control-Max-Age", p.maxAge)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// allowedMethods keeps the route's methods that are allowed cross-origin
+func (p *Policy) allowedMethods(methods []string) []string {
+	var allowed []string
+	for _, method := range methods {
+		if p.methods[method] {
+			allowed = append(allowed, method)
+		}
+	}
+	return allowed
+}
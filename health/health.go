@@ -0,0 +1,171 @@
+// Package health serves the probe endpoints both services expose:
+//
+//	/healthz  the process is up and able to answer
+//	/readyz   every readiness check passes and the process isn't shutting down
+//	/version  module version and VCS details from the build
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CheckTimeout bounds how long a single readiness check may take
+const CheckTimeout = 2 * time.Second
+
+// Check reports whether something a service depends on is usable
+type Check func(ctx context.Context) error
+
+// Checker holds a service's readiness checks and shutdown state
+type Checker struct {
+	mu           sync.Mutex
+	checks       map[string]Check
+	shuttingDown atomic.Bool
+}
+
+// NewChecker returns a checker with no checks, which is ready until
+// shutdown begins
+func NewChecker() *Checker {
+	return &Checker{checks: map[string]Check{}}
+}
+
+// AddCheck registers a readiness check under a name shown in /readyz
+func (c *Checker) AddCheck(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[name] = check
+}
+
+// SetShuttingDown makes /readyz fail from now on, so load balancers stop
+// sending traffic while in-flight requests finish
+func (c *Checker) SetShuttingDown() {
+	c.shuttingDown.Store(true)
+}
+
+// ShuttingDown reports whether shutdown has begun
+func (c *Checker) ShuttingDown() bool {
+	return c.shuttingDown.Load()
+}
+
+// Readiness is the body of a /readyz response
+type Readiness struct {
+	Status string            `json:"status"` // ready, unavailable or shutting_down
+	Checks map[string]string `json:"checks"` // Check name -> "ok" or the error
+}
+
+// Ready runs every check concurrently and reports the results
+func (c *Checker) Ready(ctx context.Context) Readiness {
+	c.mu.Lock()
+	checks := make(map[string]Check, len(c.checks))
+	for name, check := range c.checks {
+		checks[name] = check
+	}
+	c.mu.Unlock()
+
+	result := Readiness{Status: "ready", Checks: map[string]string{}}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, CheckTimeout)
+			defer cancel()
+			status := "ok"
+			err := check(checkCtx)
+			if err != nil {
+				status = err.Error()
+			}
+			mu.Lock()
+			result.Checks[name] = status
+			if err != nil {
+				result.Status = "unavailable"
+			}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if c.ShuttingDown() {
+		result.Status = "shutting_down"
+	}
+	return result
+}
+
+// LivenessHandler answers /healthz. It deliberately checks nothing else:
+// a failing dependency should take the service out of rotation, not get
+// it restarted.
+func (c *Checker) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+}
+
+// ReadinessHandler answers /readyz with 200 when ready and 503 otherwise
+func (c *Checker) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result := c.Ready(r.Context())
+		status := http.StatusOK
+		if result.Status != "ready" {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, result)
+	})
+}
+
+// BuildInfo describes the running binary
+type BuildInfo struct {
+	Module    string `json:"module"`
+	Version   string `json:"version"` // "(devel)" for a build from a checkout
+	GoVersion string `json:"goVersion"`
+	Revision  string `json:"revision,omitempty"`  // VCS commit
+	BuildTime string `json:"buildTime,omitempty"` // Commit time of Revision, RFC 3339
+	Modified  bool   `json:"modified"`            // Built with uncommitted changes
+}
+
+// ReadBuildInfo reads the module and VCS details the Go toolchain embeds.
+// VCS details are only present when built with go build inside a checkout.
+func ReadBuildInfo() BuildInfo {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return BuildInfo{Version: "unknown"}
+	}
+
+	build := BuildInfo{
+		Module:    info.Main.Path,
+		Version:   info.Main.Version,
+		GoVersion: info.GoVersion,
+	}
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			build.Revision = setting.Value
+		case "vcs.time":
+			build.BuildTime = setting.Value
+		case "vcs.modified":
+			build.Modified = setting.Value == "true"
+		}
+	}
+	return build
+}
+
+// VersionHandler answers /version
+func VersionHandler() http.Handler {
+	build := ReadBuildInfo()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, build)
+	})
+}
+
+// writeJSON sends an uncached JSON response
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,257 @@
+// Package metrics keeps counters and histograms in memory and serves them in
+// the Prometheus text exposition format, so both services can be scraped
+// without pulling in the full Prometheus client.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets suit request latencies in seconds
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// metric is anything a registry can write out
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+// Registry holds a set of metrics and writes them out together
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+	names   map[string]bool
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{names: map[string]bool{}}
+}
+
+// register adds a metric, panicking on a duplicate name since that is
+// always a programming mistake
+func (r *Registry) register(name string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[name] {
+		panic("metrics: duplicate metric " + name)
+	}
+	r.names[name] = true
+	r.metrics = append(r.metrics, m)
+}
+
+// Write writes every metric in the text exposition format
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	buf := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(buf)
+	}
+	return buf.Flush()
+}
+
+// Handler serves the registry for Prometheus to scrape
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != "GET" && req.Method != "HEAD" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		r.Write(w)
+	})
+}
+
+// series is one labelled time series of a metric
+type series struct {
+	labels string // Rendered label pairs, e.g. `method="GET",status="200"`
+	value  float64
+	counts []uint64 // Histograms only: observations per bucket
+	sum    float64  // Histograms only
+}
+
+// vec holds the series of a metric keyed by label values
+type vec struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+func newVec(name, help string, labels []string) vec {
+	return vec{name: name, help: help, labels: labels, series: map[string]*series{}}
+}
+
+// get returns the series for a set of label values, creating it if needed.
+// The caller must hold v.mu.
+func (v *vec) get(labelValues []string, buckets int) *series {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", v.name, len(v.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		pairs := make([]string, len(v.labels))
+		for i, label := range v.labels {
+			pairs[i] = label + `="` + escapeLabel(labelValues[i]) + `"`
+		}
+		s = &series{labels: strings.Join(pairs, ",")}
+		if buckets > 0 {
+			s.counts = make([]uint64, buckets)
+		}
+		v.series[key] = s
+	}
+	return s
+}
+
+// sorted returns copies of the series in label order so output is stable
+func (v *vec) sorted() []series {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	list := make([]series, 0, len(v.series))
+	for _, s := range v.series {
+		copied := *s
+		copied.counts = append([]uint64(nil), s.counts...)
+		list = append(list, copied)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].labels < list[j].labels })
+	return list
+}
+
+func (v *vec) writeHeader(w *bufio.Writer, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", v.name, escapeHelp(v.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", v.name, kind)
+}
+
+// Counter is a value that only goes up, such as the number of orders placed
+type Counter struct {
+	vec
+}
+
+// NewCounter registers a counter with the given label names
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{vec: newVec(name, help, labels)}
+	if len(labels) == 0 {
+		// Report zero rather than nothing before the first increment
+		c.get(nil, 0)
+	}
+	r.register(name, c)
+	return c
+}
+
+// Inc adds one to the series for the label values
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds a non-negative amount to the series for the label values
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		panic("metrics: counter " + c.name + " cannot decrease")
+	}
+	c.mu.Lock()
+	c.get(labelValues, 0).value += delta
+	c.mu.Unlock()
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.writeHeader(w, "counter")
+	for _, s := range c.sorted() {
+		w.WriteString(c.name + braces(s.labels) + " " + formatFloat(s.value) + "\n")
+	}
+}
+
+// Histogram counts observations, such as request latencies, into buckets
+type Histogram struct {
+	vec
+	buckets []float64 // Upper bounds, ascending, without +Inf
+}
+
+// NewHistogram registers a histogram with the given bucket upper bounds
+// and label names. Nil buckets means DefaultBuckets.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	h := &Histogram{vec: newVec(name, help, labels), buckets: buckets}
+	r.register(name, h)
+	return h
+}
+
+// Observe records a value in the series for the label values
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	h.mu.Lock()
+	s := h.get(labelValues, len(h.buckets))
+	for i, bound := range h.buckets {
+		if value <= bound {
+			s.counts[i]++
+		}
+	}
+	s.value++
+	s.sum += value
+	h.mu.Unlock()
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.writeHeader(w, "histogram")
+	for _, s := range h.sorted() {
+		prefix := s.labels
+		if prefix != "" {
+			prefix += ","
+		}
+		for i, bound := range h.buckets {
+			w.WriteString(h.name + "_bucket{" + prefix + `le="` + formatFloat(bound) + `"} ` + strconv.FormatUint(s.counts[i], 10) + "\n")
+		}
+		w.WriteString(h.name + "_bucket{" + prefix + `le="+Inf"} ` + formatFloat(s.value) + "\n")
+		w.WriteString(h.name + "_sum" + braces(s.labels) + " " + formatFloat(s.sum) + "\n")
+		w.WriteString(h.name + "_count" + braces(s.labels) + " " + formatFloat(s.value) + "\n")
+	}
+}
+
+// braces wraps rendered labels, or returns nothing for an unlabelled series
+func braces(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+// formatFloat writes numbers the way Prometheus expects
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
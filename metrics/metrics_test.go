@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExpositionFormat(t *testing.T) {
+	r := NewRegistry()
+	orders := r.NewCounter("orders_total", "Orders placed.")
+	requests := r.NewCounter("http_requests_total", "Requests by route\nand status.", "route", "status")
+	latency := r.NewHistogram("http_request_duration_seconds", "Request latency.", []float64{0.5, 0.1}, "route")
+
+	requests.Inc("/products/{id}", "200")
+	requests.Add(2, "/orders", "201")
+	requests.Inc(`/odd"path\`, "404")
+	latency.Observe(0.05, "/orders")
+	latency.Observe(0.3, "/orders")
+	latency.Observe(2, "/orders")
+
+	var out strings.Builder
+	if err := r.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP orders_total Orders placed.
+# TYPE orders_total counter
+orders_total 0
+# HELP http_requests_total Requests by route\nand status.
+# TYPE http_requests_total counter
+http_requests_total{route="/odd\"path\\",status="404"} 1
+http_requests_total{route="/orders",status="201"} 2
+http_requests_total{route="/products/{id}",status="200"} 1
+# HELP http_request_duration_seconds Request latency.
+# TYPE http_request_duration_seconds histogram
+http_request_duration_seconds_bucket{route="/orders",le="0.1"} 1
+http_request_duration_seconds_bucket{route="/orders",le="0.5"} 2
+http_request_duration_seconds_bucket{route="/orders",le="+Inf"} 3
+http_request_duration_seconds_sum{route="/orders"} 2.35
+http_request_duration_seconds_count{route="/orders"} 3
+`
+	if out.String() != want {
+		t.Errorf("exposition:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	orders.Inc()
+	out.Reset()
+	r.Write(&out)
+	if !strings.Contains(out.String(), "\norders_total 1\n") {
+		t.Errorf("counter not incremented:\n%s", out.String())
+	}
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("up", "Always one.").Inc()
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if w.Code != 200 || w.Header().Get("Content-Type") != "text/plain; version=0.0.4; charset=utf-8" || !strings.Contains(w.Body.String(), "up 1\n") {
+		t.Errorf("GET /metrics: %d %q\n%s", w.Code, w.Header().Get("Content-Type"), w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest("POST", "/metrics", nil))
+	if w.Code != 405 {
+		t.Errorf("POST /metrics: status %d, want 405", w.Code)
+	}
+}
+
+func TestMisuse(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("c", "A counter.", "label")
+
+	panics := map[string]func(){
+		"duplicate name":     func() { r.NewCounter("c", "Again.") },
+		"wrong label count":  func() { c.Inc() },
+		"negative increment": func() { c.Add(-1, "x") },
+	}
+	for name, f := range panics {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s didn't panic", name)
+				}
+			}()
+			f()
+		}()
+	}
+}
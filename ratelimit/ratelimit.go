@@ -0,0 +1,78 @@
+// Package ratelimit limits how fast each client may make requests, using a
+// token bucket per client: a client may make Burst requests at once, and
+// the bucket refills at RequestsPerMinute.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often buckets of idle clients are dropped
+const sweepInterval = time.Minute
+
+// Limiter tracks a bucket per client
+type Limiter struct {
+	mu        sync.Mutex
+	perSecond float64
+	burst     float64
+	clients   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// New returns a limiter allowing requestsPerMinute per client, with bursts
+// of up to burst requests
+func New(requestsPerMinute, burst int) *Limiter {
+	return &Limiter{
+		perSecond: float64(requestsPerMinute) / 60,
+		burst:     float64(burst),
+		clients:   map[string]*bucket{},
+		now:       time.Now,
+	}
+}
+
+// Allow takes a token from a client's bucket. When the bucket is empty it
+// reports false and how long until the client may try again.
+func (l *Limiter) Allow(client string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.clients[client]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.clients[client] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.perSecond)
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / l.perSecond * float64(time.Second))
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
+
+// sweep drops the buckets of clients that have been away long enough to
+// refill, so the map only holds clients seen recently
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for client, b := range l.clients {
+		if b.tokens+now.Sub(b.last).Seconds()*l.perSecond >= l.burst {
+			delete(l.clients, client)
+		}
+	}
+}
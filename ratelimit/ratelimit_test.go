@@ -0,0 +1,53 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllow(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := New(60, 2) // One request a second, two at once
+	l.now = func() time.Time { return now }
+
+	steps := []struct {
+		advance time.Duration
+		client  string
+		allowed bool
+		wait    time.Duration
+	}{
+		{0, "a", true, 0},
+		{0, "a", true, 0},
+		{0, "a", false, time.Second},
+		{0, "b", true, 0}, // Clients have their own buckets
+		{500 * time.Millisecond, "a", false, 500 * time.Millisecond},
+		{500 * time.Millisecond, "a", true, 0},
+		{10 * time.Second, "a", true, 0}, // Refills only up to the burst
+		{0, "a", true, 0},
+		{0, "a", false, time.Second},
+	}
+	for i, step := range steps {
+		now = now.Add(step.advance)
+		allowed, wait := l.Allow(step.client)
+		if allowed != step.allowed || wait != step.wait {
+			t.Errorf("step %d: Allow(%q) = %v, %v; want %v, %v", i, step.client, allowed, wait, step.allowed, step.wait)
+		}
+	}
+}
+
+func TestSweepDropsIdleClients(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := New(60, 2)
+	l.now = func() time.Time { return now }
+
+	l.Allow("idle")
+	now = now.Add(sweepInterval)
+	l.Allow("busy")
+
+	if _, ok := l.clients["idle"]; ok {
+		t.Error("idle client's full bucket was kept")
+	}
+	if _, ok := l.clients["busy"]; !ok {
+		t.Error("busy client's bucket was dropped")
+	}
+}
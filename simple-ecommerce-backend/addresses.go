@@ -0,0 +1,236 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// SavedAddress represents an address in a user's address book
+type SavedAddress struct {
+	ID     string `json:"id"`
+	UserID string `json:"userId"`
+	Label  string `json:"label,omitempty"` // e.g. "Home" or "Work"
+	Address
+	DefaultShipping bool      `json:"defaultShipping"`
+	DefaultBilling  bool      `json:"defaultBilling"`
+	CreatedAt       time.Time `json:"createdAt"`
+}
+
+// In-memory address book for demo purposes
+var savedAddresses = []SavedAddress{
+	{
+		ID:     "a1",
+		UserID: "u1",
+		Label:  "Home",
+		Address: Address{
+			Street:  "123 Main St",
+			City:    "Anytown",
+			State:   "CA",
+			ZipCode: "12345",
+			Country: "USA",
+		},
+		DefaultShipping: true,
+		DefaultBilling:  true,
+		CreatedAt:       time.Now().Add(-30 * 24 * time.Hour),
+	},
+}
+
+// nextAddressID counts every address ever created so deleted IDs aren't reused
+var nextAddressID = len(savedAddresses) + 1
+
+// findSavedAddress looks up one of a user's saved addresses
+func findSavedAddress(userID, addressID string) *SavedAddress {
+	for i := range savedAddresses {
+		if savedAddresses[i].ID == addressID && savedAddresses[i].UserID == userID {
+			return &savedAddresses[i]
+		}
+	}
+	return nil
+}
+
+// defaultAddress returns a user's default shipping or billing address
+func defaultAddress(userID string, billing bool) *SavedAddress {
+	for i := range savedAddresses {
+		if savedAddresses[i].UserID != userID {
+			continue
+		}
+		if (billing && savedAddresses[i].DefaultBilling) || (!billing && savedAddresses[i].DefaultShipping) {
+			return &savedAddresses[i]
+		}
+	}
+	return nil
+}
+
+// setAddressDefaults makes an address the only default of its kind for the user
+func setAddressDefaults(addr *SavedAddress) {
+	for i := range savedAddresses {
+		if savedAddresses[i].UserID != addr.UserID || savedAddresses[i].ID == addr.ID {
+			continue
+		}
+		if addr.DefaultShipping {
+			savedAddresses[i].DefaultShipping = false
+		}
+		if addr.DefaultBilling {
+			savedAddresses[i].DefaultBilling = false
+		}
+	}
+}
+
+// handleAddresses processes requests for a user's address book
+func handleAddresses(w http.ResponseWriter, r *http.Request, userID string, pathParts []string) {
+	// Find user by ID
+	user := findUser(r.Context(), userID)
+
+	// User not found
+	if user == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "User not found"})
+		return
+	}
+
+	// Handle specific address
+	if len(pathParts) > 4 && pathParts[4] != "" {
+		handleSingleAddress(w, r, userID, pathParts[4])
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		userAddresses := []SavedAddress{}
+		for _, addr := range savedAddresses {
+			if addr.UserID == userID {
+				userAddresses = append(userAddresses, addr)
+			}
+		}
+		json.NewEncoder(w).Encode(userAddresses)
+	case "POST":
+		createAddress(w, r, userID)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// createAddress adds an address to a user's address book
+func createAddress(w http.ResponseWriter, r *http.Request, userID string) {
+	var addr SavedAddress
+	err := json.NewDecoder(r.Body).Decode(&addr)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	err = validateAddress(addr.Address)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	// First address becomes the default for everything
+	if defaultAddress(userID, false) == nil {
+		addr.DefaultShipping = true
+	}
+	if defaultAddress(userID, true) == nil {
+		addr.DefaultBilling = true
+	}
+
+	addr.ID = "a" + strconv.Itoa(nextAddressID)
+	nextAddressID++
+	addr.UserID = userID
+	addr.CreatedAt = time.Now()
+
+	savedAddresses = append(savedAddresses, addr)
+	setAddressDefaults(&addr)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(addr)
+}
+
+// handleSingleAddress reads, updates or deletes one saved address
+func handleSingleAddress(w http.ResponseWriter, r *http.Request, userID, addressID string) {
+	addr := findSavedAddress(userID, addressID)
+
+	// Address not found
+	if addr == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Address not found"})
+		return
+	}
+
+	// GET - Return address details
+	if r.Method == "GET" {
+		json.NewEncoder(w).Encode(addr)
+		return
+	}
+
+	// PUT - Update address
+	if r.Method == "PUT" {
+		var updatedAddr SavedAddress
+		err := json.NewDecoder(r.Body).Decode(&updatedAddr)
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		err = validateAddress(updatedAddr.Address)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		// Preserve identity
+		updatedAddr.ID = addr.ID
+		updatedAddr.UserID = addr.UserID
+		updatedAddr.CreatedAt = addr.CreatedAt
+
+		// Defaults can be moved to another address but not simply cleared
+		if addr.DefaultShipping {
+			updatedAddr.DefaultShipping = true
+		}
+		if addr.DefaultBilling {
+			updatedAddr.DefaultBilling = true
+		}
+
+		*addr = updatedAddr
+		setAddressDefaults(addr)
+
+		json.NewEncoder(w).Encode(addr)
+		return
+	}
+
+	// DELETE - Remove address
+	if r.Method == "DELETE" {
+		removed := *addr
+		var newAddresses []SavedAddress
+		for i := range savedAddresses {
+			if savedAddresses[i].ID != addressID {
+				newAddresses = append(newAddresses, savedAddresses[i])
+			}
+		}
+		savedAddresses = newAddresses
+
+		// Hand defaults over to the user's oldest remaining address
+		for i := range savedAddresses {
+			if savedAddresses[i].UserID != userID {
+				continue
+			}
+			if removed.DefaultShipping {
+				savedAddresses[i].DefaultShipping = true
+			}
+			if removed.DefaultBilling {
+				savedAddresses[i].DefaultBilling = true
+			}
+			break
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Address deleted"})
+		return
+	}
+
+	// Method not allowed
+	w.WriteHeader(http.StatusMethodNotAllowed)
+}
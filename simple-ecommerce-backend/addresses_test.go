@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// saveAddress adds an address to a user's book and returns its ID
+func saveAddress(t *testing.T, userID, body string) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	createAddress(w, httptest.NewRequest("POST", "/users/"+userID+"/addresses", strings.NewReader(body)), userID)
+	if w.Code != 201 {
+		t.Fatalf("create address: status %d: %s", w.Code, w.Body)
+	}
+	var addr SavedAddress
+	json.NewDecoder(w.Body).Decode(&addr)
+	return addr.ID
+}
+
+// deleteAddress removes one of a user's addresses
+func deleteAddress(t *testing.T, userID, addressID string) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handleSingleAddress(w, httptest.NewRequest("DELETE", "/users/"+userID+"/addresses/"+addressID, nil), userID, addressID)
+	if w.Code != 200 {
+		t.Fatalf("delete %s: status %d: %s", addressID, w.Code, w.Body)
+	}
+}
+
+func TestDeletingADefaultAddressHandsItOver(t *testing.T) {
+	keepSlice(t, &savedAddresses)
+	keepValue(t, &nextAddressID)
+	savedAddresses = nil
+
+	address := `"street":"1 Main St","city":"Sacramento","zipCode":"95814","country":"USA"`
+	other := saveAddress(t, "u2", `{`+address+`}`)
+	first := saveAddress(t, "u1", `{`+address+`}`)
+	work := saveAddress(t, "u1", `{"label":"Work",`+address+`,"defaultShipping":true}`)
+	spare := saveAddress(t, "u1", `{`+address+`}`)
+
+	if defaultAddress("u1", false).ID != work || defaultAddress("u1", true).ID != first {
+		t.Fatalf("defaults before deleting: shipping %s, billing %s", defaultAddress("u1", false).ID, defaultAddress("u1", true).ID)
+	}
+
+	// Shipping goes back to the oldest remaining address
+	deleteAddress(t, "u1", work)
+	if got := defaultAddress("u1", false); got == nil || got.ID != first {
+		t.Errorf("shipping default after deleting %s: %+v, want %s", work, got, first)
+	}
+
+	// Both defaults move on together, and never to another user's address
+	deleteAddress(t, "u1", first)
+	for _, billing := range []bool{false, true} {
+		if got := defaultAddress("u1", billing); got == nil || got.ID != spare {
+			t.Errorf("default (billing %v) after deleting %s: %+v, want %s", billing, first, got, spare)
+		}
+	}
+	if got := findSavedAddress("u2", other); !got.DefaultShipping || !got.DefaultBilling {
+		t.Errorf("other user's address lost its defaults: %+v", got)
+	}
+
+	// Deleting the last address leaves no default
+	deleteAddress(t, "u1", spare)
+	if defaultAddress("u1", false) != nil || defaultAddress("u1", true) != nil {
+		t.Error("defaults left after deleting every address")
+	}
+}
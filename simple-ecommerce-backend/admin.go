@@ -0,0 +1,381 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Pagination limits for admin listings
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// OrderSearchResult is a page of orders matching a staff search
+type OrderSearchResult struct {
+	Orders   []Order `json:"orders"`
+	Total    int     `json:"total"` // Matches across all pages
+	Page     int     `json:"page"`
+	PageSize int     `json:"pageSize"`
+}
+
+// OrderFilter holds the criteria of a staff order search
+type OrderFilter struct {
+	Status    []string
+	UserID    string
+	ProductID string
+	From      time.Time // Inclusive; zero means unbounded
+	To        time.Time // Exclusive; zero means unbounded
+	MinTotal  float64
+	MaxTotal  float64 // Zero means unbounded
+}
+
+// parseDateParam accepts either an RFC 3339 timestamp or a plain date
+func parseDateParam(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// parseOrderFilter reads search criteria from query parameters. A plain
+// "to" date includes the whole of that day.
+func parseOrderFilter(query url.Values) (OrderFilter, error) {
+	var filter OrderFilter
+	var err error
+
+	if status := query.Get("status"); status != "" {
+		filter.Status = strings.Split(status, ",")
+	}
+	filter.UserID = query.Get("userId")
+	filter.ProductID = query.Get("productId")
+
+	if from := query.Get("from"); from != "" {
+		filter.From, err = parseDateParam(from)
+		if err != nil {
+			return filter, err
+		}
+	}
+	if to := query.Get("to"); to != "" {
+		filter.To, err = parseDateParam(to)
+		if err != nil {
+			return filter, err
+		}
+		if len(to) == len("2006-01-02") {
+			filter.To = filter.To.AddDate(0, 0, 1)
+		}
+	}
+	if minTotal := query.Get("minTotal"); minTotal != "" {
+		filter.MinTotal, err = strconv.ParseFloat(minTotal, 64)
+		if err != nil {
+			return filter, err
+		}
+	}
+	if maxTotal := query.Get("maxTotal"); maxTotal != "" {
+		filter.MaxTotal, err = strconv.ParseFloat(maxTotal, 64)
+		if err != nil {
+			return filter, err
+		}
+	}
+
+	return filter, nil
+}
+
+// matches reports whether an order meets every criterion of the filter
+func (f OrderFilter) matches(order Order) bool {
+	if len(f.Status) > 0 {
+		found := false
+		for _, status := range f.Status {
+			if order.Status == status {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.UserID != "" && order.UserID != f.UserID {
+		return false
+	}
+	if f.ProductID != "" {
+		found := false
+		for _, item := range order.Items {
+			if item.ProductID == f.ProductID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if !f.From.IsZero() && order.CreatedAt.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !order.CreatedAt.Before(f.To) {
+		return false
+	}
+	if order.TotalAmount < f.MinTotal {
+		return false
+	}
+	if f.MaxTotal > 0 && order.TotalAmount > f.MaxTotal {
+		return false
+	}
+	return true
+}
+
+// pageBounds reads page and pageSize query parameters and returns the
+// slice bounds of that page within a list of total items
+func pageBounds(query url.Values, total int) (page, pageSize, start, end int) {
+	page, _ = strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ = strconv.Atoi(query.Get("pageSize"))
+	if pageSize < 1 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+
+	start = (page - 1) * pageSize
+	if start > total {
+		start = total
+	}
+	end = start + pageSize
+	if end > total {
+		end = total
+	}
+	return page, pageSize, start, end
+}
+
+// filterOrders returns the orders matching a filter, in creation order
+func filterOrders(filter OrderFilter) []Order {
+	matched := []Order{}
+	for _, order := range orders {
+		if filter.matches(order) {
+			matched = append(matched, order)
+		}
+	}
+	return matched
+}
+
+// orderSorters are the fields staff can sort orders by
+var orderSorters = map[string]func(a, b Order) bool{
+	"createdAt": func(a, b Order) bool { return a.CreatedAt.Before(b.CreatedAt) },
+	"total":     func(a, b Order) bool { return a.TotalAmount < b.TotalAmount },
+	"status":    func(a, b Order) bool { return a.Status < b.Status },
+	"userId":    func(a, b Order) bool { return a.UserID < b.UserID },
+}
+
+// searchOrders filters and sorts orders. Sort is a field name, optionally
+// prefixed with "-" for descending; the default is newest first.
+func searchOrders(filter OrderFilter, sortBy string) ([]Order, bool) {
+	if sortBy == "" {
+		sortBy = "-createdAt"
+	}
+	descending := strings.HasPrefix(sortBy, "-")
+	less, ok := orderSorters[strings.TrimPrefix(sortBy, "-")]
+	if !ok {
+		return nil, false
+	}
+
+	matched := filterOrders(filter)
+	sort.SliceStable(matched, func(i, j int) bool {
+		if descending {
+			return less(matched[j], matched[i])
+		}
+		return less(matched[i], matched[j])
+	})
+
+	return matched, true
+}
+
+// adminRoutes lists the routes Handler serves, for CORS preflights and metrics
+var adminRoutes = routeTable{
+	"/admin/reviews":               {"GET"},
+	"/admin/reviews/{id}/hide":     {"POST"},
+	"/admin/reviews/{id}/publish":  {"POST"},
+	"/admin/products/import":       {"POST"},
+	"/admin/products/export":       {"GET"},
+	"/admin/products/archived":     {"GET"},
+	"/admin/products/purge":        {"POST"},
+	"/admin/products/{id}/restore": {"POST"},
+	"/admin/products/{id}/purge":   {"POST"},
+	"/admin/orders":                {"GET"},
+	"/admin/analytics/{id}":        {"GET"},
+	"/admin/promotions":            {"GET"},
+	"/admin/notifications":         {"GET"},
+	"/admin/notifications/{id}":    {"DELETE"},
+}
+
+// Handler processes staff admin requests
+func Handler(w http.ResponseWriter, r *http.Request) {
+	// Tag the request with an ID and log it when done
+	w, r, done, ok := beginRequest(w, r, adminRoutes)
+	defer done()
+
+	// Refuse to serve with an invalid configuration
+	if !ok {
+		return
+	}
+
+	// Add CORS headers and answer preflight requests
+	if handleCORS(w, r, adminRoutes) {
+		return
+	}
+
+	// Turn away clients over the rate limit
+	if rateLimited(w, r) {
+		return
+	}
+
+	// Set content type
+	w.Header().Set("Content-Type", "application/json")
+
+	// Every admin endpoint is for staff only
+	if requireStaff(w, r) {
+		return
+	}
+
+	// Extract path parts
+	path := r.URL.Path
+	pathParts := strings.Split(path, "/")
+
+	// Handle review moderation endpoints
+	if len(pathParts) > 2 && pathParts[2] == "reviews" {
+		handleReviewModeration(w, r, pathParts)
+		return
+	}
+
+	// Handle catalog import, export and archive endpoints
+	if len(pathParts) > 3 && pathParts[2] == "products" {
+		handleAdminProducts(w, r, pathParts)
+		return
+	}
+
+	// Handle the back-in-stock notification queue
+	if len(pathParts) > 2 && pathParts[2] == "notifications" {
+		handleNotifications(w, r, pathParts)
+		return
+	}
+
+	// Remaining endpoints are read-only reports
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Handle order search endpoint
+	if len(pathParts) > 2 && pathParts[2] == "orders" {
+		adminSearchOrders(w, r)
+		return
+	}
+
+	// List every promotion, codes and usage limits included
+	if len(pathParts) > 2 && pathParts[2] == "promotions" {
+		_, span := startSpan(r, "adminListPromotions")
+		defer span.End()
+		json.NewEncoder(w).Encode(promotions)
+		return
+	}
+
+	// Handle analytics endpoints
+	if len(pathParts) > 3 && pathParts[2] == "analytics" {
+		handleAnalytics(w, r, pathParts[3])
+		return
+	}
+
+	w.WriteHeader(http.StatusNotFound)
+	json.NewEncoder(w).Encode(map[string]string{"error": "Not found"})
+}
+
+// adminSearchOrders lists orders matching the query, as JSON pages or a CSV export
+func adminSearchOrders(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "adminSearchOrders")
+	defer span.End()
+
+	query := r.URL.Query()
+
+	filter, err := parseOrderFilter(query)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid filter: " + err.Error()})
+		return
+	}
+
+	matched, ok := searchOrders(filter, query.Get("sort"))
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Unknown sort field"})
+		return
+	}
+
+	// CSV exports the whole result set, not just one page
+	if query.Get("format") == "csv" {
+		writeOrdersCSV(w, matched)
+		return
+	}
+
+	page, pageSize, start, end := pageBounds(query, len(matched))
+
+	json.NewEncoder(w).Encode(OrderSearchResult{
+		Orders:   matched[start:end],
+		Total:    len(matched),
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
+
+// writeOrdersCSV writes one row per order for reconciliation. Text that
+// came from customers is guarded against formula injection.
+func writeOrdersCSV(w http.ResponseWriter, list []Order) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="orders.csv"`)
+
+	out := csv.NewWriter(w)
+	out.Write([]string{
+		"id", "userId", "status", "createdAt", "items", "units",
+		"subtotal", "discount", "shipping", "tax", "total", "refunded", "couponCode", "country",
+	})
+
+	decimal := func(amount float64) string {
+		return strconv.FormatFloat(amount, 'f', 2, 64)
+	}
+	for _, order := range list {
+		var units int
+		for _, item := range order.Items {
+			units += item.Quantity
+		}
+		var discount float64
+		for _, line := range order.Discounts {
+			discount += line.Amount
+		}
+		out.Write([]string{
+			spreadsheetCell(order.ID),
+			spreadsheetCell(order.UserID),
+			order.Status,
+			order.CreatedAt.Format(time.RFC3339),
+			strconv.Itoa(len(order.Items)),
+			strconv.Itoa(units),
+			decimal(order.Subtotal),
+			decimal(discount),
+			decimal(order.ShippingCost),
+			decimal(order.TaxTotal),
+			decimal(order.TotalAmount),
+			decimal(order.RefundedAmount),
+			spreadsheetCell(order.CouponCode),
+			spreadsheetCell(order.ShippingAddr.Country),
+		})
+	}
+	out.Flush()
+}
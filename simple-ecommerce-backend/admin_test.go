@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// searchFixture replaces the orders with a small known set
+func searchFixture(t *testing.T) {
+	keepStore(t)
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 12, 0, 0, 0, time.UTC) }
+	orders = []Order{
+		{ID: "o1", UserID: "u1", Status: "pending", TotalAmount: 50, CreatedAt: day(1), Items: []OrderItem{{ProductID: "p1", Quantity: 1}}},
+		{ID: "o2", UserID: "u2", Status: "shipped", TotalAmount: 150, CreatedAt: day(2), Items: []OrderItem{{ProductID: "p2", Quantity: 2}}},
+		{ID: "o3", UserID: "u1", Status: "delivered", TotalAmount: 25, CreatedAt: day(3), Items: []OrderItem{{ProductID: "p1", Quantity: 3}}},
+		{ID: "o4", UserID: "=HYPERLINK(\"http://x\")", Status: "shipped", TotalAmount: 99, CreatedAt: day(4), CouponCode: "@SUM(A1)"},
+	}
+}
+
+// orderIDs lists the IDs of orders in order
+func orderIDs(list []Order) string {
+	ids := []string{}
+	for _, order := range list {
+		ids = append(ids, order.ID)
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestSearchOrdersFiltersAndSorts(t *testing.T) {
+	searchFixture(t)
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"", "o4,o3,o2,o1"},
+		{"status=shipped,pending", "o4,o2,o1"},
+		{"userId=u1", "o3,o1"},
+		{"productId=p1", "o3,o1"},
+		{"from=2026-03-02&to=2026-03-03", "o3,o2"},
+		{"from=2026-03-02T13:00:00Z", "o4,o3"},
+		{"minTotal=50&maxTotal=100", "o4,o1"},
+		{"sort=total", "o3,o1,o4,o2"},
+		{"sort=-total", "o2,o4,o1,o3"},
+		{"sort=status", "o3,o1,o2,o4"},
+	}
+	for _, tc := range tests {
+		query, _ := url.ParseQuery(tc.query)
+		filter, err := parseOrderFilter(query)
+		if err != nil {
+			t.Errorf("%q: %v", tc.query, err)
+			continue
+		}
+		matched, ok := searchOrders(filter, query.Get("sort"))
+		if !ok {
+			t.Errorf("%q: sort refused", tc.query)
+			continue
+		}
+		if got := orderIDs(matched); got != tc.want {
+			t.Errorf("%q: got %s, want %s", tc.query, got, tc.want)
+		}
+	}
+
+	if _, ok := searchOrders(OrderFilter{}, "password"); ok {
+		t.Error("unknown sort field accepted")
+	}
+	if _, err := parseOrderFilter(url.Values{"from": {"yesterday"}}); err == nil {
+		t.Error("unparseable date accepted")
+	}
+}
+
+func TestPageBounds(t *testing.T) {
+	tests := []struct {
+		query                      string
+		total                      int
+		page, pageSize, start, end int
+	}{
+		{"", 45, 1, defaultPageSize, 0, 20},
+		{"page=3", 45, 3, defaultPageSize, 40, 45},
+		{"page=9", 45, 9, defaultPageSize, 45, 45},
+		{"page=-1&pageSize=0", 45, 1, defaultPageSize, 0, 20},
+		{"page=2&pageSize=500", 450, 2, maxPageSize, 100, 200},
+	}
+	for _, tc := range tests {
+		query, _ := url.ParseQuery(tc.query)
+		page, pageSize, start, end := pageBounds(query, tc.total)
+		if page != tc.page || pageSize != tc.pageSize || start != tc.start || end != tc.end {
+			t.Errorf("%q of %d: got page %d size %d [%d:%d], want page %d size %d [%d:%d]",
+				tc.query, tc.total, page, pageSize, start, end, tc.page, tc.pageSize, tc.start, tc.end)
+		}
+	}
+}
+
+func TestAdminSearchOrdersPagesAndExports(t *testing.T) {
+	searchFixture(t)
+
+	w := httptest.NewRecorder()
+	adminSearchOrders(w, httptest.NewRequest("GET", "/admin/orders?sort=total&pageSize=2&page=2", nil))
+	var result OrderSearchResult
+	json.NewDecoder(w.Body).Decode(&result)
+	if result.Total != 4 || result.Page != 2 || orderIDs(result.Orders) != "o4,o2" {
+		t.Errorf("page 2: total %d, page %d, orders %s", result.Total, result.Page, orderIDs(result.Orders))
+	}
+
+	w = httptest.NewRecorder()
+	adminSearchOrders(w, httptest.NewRequest("GET", "/admin/orders?format=csv&pageSize=1", nil))
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil || len(rows) != 5 {
+		t.Fatalf("CSV export: %d rows, %v; want a header and every match", len(rows), err)
+	}
+	if rows[1][1] != `'=HYPERLINK("http://x")` || rows[1][12] != "'@SUM(A1)" {
+		t.Errorf("formulas exported as %q and %q", rows[1][1], rows[1][12])
+	}
+
+	w = httptest.NewRecorder()
+	adminSearchOrders(w, httptest.NewRequest("GET", "/admin/orders?minTotal=lots", nil))
+	if w.Code != 400 {
+		t.Errorf("bad filter: status %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,294 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// defaultAnalyticsDays is the reporting window when no "from" is given
+const defaultAnalyticsDays = 30
+
+// RevenuePoint is revenue for one day, week or month
+type RevenuePoint struct {
+	Period  string  `json:"period"` // 2006-01-02, 2006-W01 or 2006-01
+	Orders  int     `json:"orders"`
+	Units   int     `json:"units"`
+	Revenue float64 `json:"revenue"`
+}
+
+// SalesSummary holds headline figures for a date range
+type SalesSummary struct {
+	From              time.Time `json:"from"`
+	To                time.Time `json:"to"`
+	Orders            int       `json:"orders"`
+	Units             int       `json:"units"`
+	Revenue           float64   `json:"revenue"` // Order totals less refunds
+	Refunded          float64   `json:"refunded"`
+	AverageOrderValue float64   `json:"averageOrderValue"`
+}
+
+// ProductSales is units sold and revenue for one product
+type ProductSales struct {
+	ProductID string  `json:"productId"`
+	Name      string  `json:"name"`
+	Units     int     `json:"units"`
+	Revenue   float64 `json:"revenue"` // Line totals before order-level discounts
+	Orders    int     `json:"orders"`
+}
+
+// CustomerSales is spend for one customer
+type CustomerSales struct {
+	UserID  string  `json:"userId"`
+	Name    string  `json:"name,omitempty"`
+	Email   string  `json:"email,omitempty"`
+	Orders  int     `json:"orders"`
+	Revenue float64 `json:"revenue"`
+}
+
+// ConversionStats compares carts that turned into orders with carts that didn't
+type ConversionStats struct {
+	ConvertedUsers int     `json:"convertedUsers"` // Users who placed an order in range
+	OpenCarts      int     `json:"openCarts"`      // Users with items still in their cart
+	AbandonedCarts int     `json:"abandonedCarts"` // Open carts of users who didn't order in range
+	ConversionRate float64 `json:"conversionRate"` // Converted / (converted + abandoned)
+}
+
+// analyticsRange reads from/to query parameters, defaulting to the last 30 days
+func analyticsRange(r *http.Request) (time.Time, time.Time, error) {
+	query := r.URL.Query()
+	to := time.Now()
+	from := to.AddDate(0, 0, -defaultAnalyticsDays)
+	var err error
+
+	if value := query.Get("from"); value != "" {
+		from, err = parseDateParam(value)
+		if err != nil {
+			return from, to, err
+		}
+	}
+	if value := query.Get("to"); value != "" {
+		to, err = parseDateParam(value)
+		if err != nil {
+			return from, to, err
+		}
+		if len(value) == len("2006-01-02") {
+			to = to.AddDate(0, 0, 1)
+		}
+	}
+
+	return from, to, nil
+}
+
+// ordersInRange returns orders created in [from, to)
+func ordersInRange(from, to time.Time) []Order {
+	return filterOrders(OrderFilter{From: from, To: to})
+}
+
+// netRevenue is what an order brought in after refunds
+func netRevenue(order Order) float64 {
+	return order.TotalAmount - order.RefundedAmount
+}
+
+// periodKey buckets a time into a day, ISO week or month
+func periodKey(t time.Time, interval string) string {
+	switch interval {
+	case "week":
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case "month":
+		return t.Format("2006-01")
+	default:
+		return t.Format("2006-01-02")
+	}
+}
+
+// revenueByPeriod totals revenue per day, week or month
+func revenueByPeriod(list []Order, interval string) []RevenuePoint {
+	points := map[string]*RevenuePoint{}
+	for _, order := range list {
+		key := periodKey(order.CreatedAt, interval)
+		point, ok := points[key]
+		if !ok {
+			point = &RevenuePoint{Period: key}
+			points[key] = point
+		}
+		point.Orders++
+		point.Revenue += netRevenue(order)
+		for _, item := range order.Items {
+			point.Units += item.Quantity
+		}
+	}
+
+	result := []RevenuePoint{}
+	for _, point := range points {
+		point.Revenue = roundCents(point.Revenue)
+		result = append(result, *point)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Period < result[j].Period
+	})
+	return result
+}
+
+// summarizeSales works out headline figures for a set of orders
+func summarizeSales(list []Order, from, to time.Time) SalesSummary {
+	summary := SalesSummary{From: from, To: to, Orders: len(list)}
+	for _, order := range list {
+		summary.Revenue += netRevenue(order)
+		summary.Refunded += order.RefundedAmount
+		for _, item := range order.Items {
+			summary.Units += item.Quantity
+		}
+	}
+	summary.Revenue = roundCents(summary.Revenue)
+	summary.Refunded = roundCents(summary.Refunded)
+	if summary.Orders > 0 {
+		summary.AverageOrderValue = roundCents(summary.Revenue / float64(summary.Orders))
+	}
+	return summary
+}
+
+// salesByProduct totals units and revenue per product, best sellers first
+func salesByProduct(list []Order) []ProductSales {
+	byProduct := map[string]*ProductSales{}
+	for _, order := range list {
+		for _, item := range order.Items {
+			sales, ok := byProduct[item.ProductID]
+			if !ok {
+				sales = &ProductSales{ProductID: item.ProductID, Name: item.Name}
+				byProduct[item.ProductID] = sales
+			}
+			sales.Units += item.Quantity
+			sales.Revenue += item.Price * float64(item.Quantity)
+			sales.Orders++
+		}
+	}
+
+	result := []ProductSales{}
+	for _, sales := range byProduct {
+		sales.Revenue = roundCents(sales.Revenue)
+		result = append(result, *sales)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Revenue != result[j].Revenue {
+			return result[i].Revenue > result[j].Revenue
+		}
+		return result[i].ProductID < result[j].ProductID
+	})
+	return result
+}
+
+// salesByCustomer totals spend per customer, biggest spenders first
+func salesByCustomer(ctx context.Context, list []Order) []CustomerSales {
+	byUser := map[string]*CustomerSales{}
+	for _, order := range list {
+		sales, ok := byUser[order.UserID]
+		if !ok {
+			sales = &CustomerSales{UserID: order.UserID}
+			if user := findUser(ctx, order.UserID); user != nil {
+				sales.Name = user.Name
+				sales.Email = user.Email
+			}
+			byUser[order.UserID] = sales
+		}
+		sales.Orders++
+		sales.Revenue += netRevenue(order)
+	}
+
+	result := []CustomerSales{}
+	for _, sales := range byUser {
+		sales.Revenue = roundCents(sales.Revenue)
+		result = append(result, *sales)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Revenue != result[j].Revenue {
+			return result[i].Revenue > result[j].Revenue
+		}
+		return result[i].UserID < result[j].UserID
+	})
+	return result
+}
+
+// cartConversion compares users who ordered in range with users whose
+// carts still hold items. Carts are emptied at checkout, so an open cart
+// belonging to someone who didn't order is counted as abandoned.
+func cartConversion(list []Order) ConversionStats {
+	converted := map[string]bool{}
+	for _, order := range list {
+		converted[order.UserID] = true
+	}
+
+	stats := ConversionStats{ConvertedUsers: len(converted)}
+	for _, cart := range carts {
+		if len(cart.Items) == 0 {
+			continue
+		}
+		stats.OpenCarts++
+		if !converted[cart.UserID] {
+			stats.AbandonedCarts++
+		}
+	}
+
+	if total := stats.ConvertedUsers + stats.AbandonedCarts; total > 0 {
+		stats.ConversionRate = math.Round(float64(stats.ConvertedUsers)/float64(total)*10000) / 10000
+	}
+	return stats
+}
+
+// handleAnalytics serves the admin analytics reports
+func handleAnalytics(w http.ResponseWriter, r *http.Request, report string) {
+	r, span := startSpan(r, "handleAnalytics", attribute.String("report", report))
+	defer span.End()
+
+	from, to, err := analyticsRange(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid date range: " + err.Error()})
+		return
+	}
+	list := ordersInRange(from, to)
+
+	// Optional limit for ranked reports
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	switch report {
+	case "summary":
+		json.NewEncoder(w).Encode(summarizeSales(list, from, to))
+	case "revenue":
+		interval := r.URL.Query().Get("interval")
+		if interval == "" {
+			interval = "day"
+		}
+		if interval != "day" && interval != "week" && interval != "month" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Interval must be day, week or month"})
+			return
+		}
+		json.NewEncoder(w).Encode(revenueByPeriod(list, interval))
+	case "products":
+		result := salesByProduct(list)
+		if limit > 0 && limit < len(result) {
+			result = result[:limit]
+		}
+		json.NewEncoder(w).Encode(result)
+	case "customers":
+		result := salesByCustomer(r.Context(), list)
+		if limit > 0 && limit < len(result) {
+			result = result[:limit]
+		}
+		json.NewEncoder(w).Encode(result)
+	case "conversion":
+		json.NewEncoder(w).Encode(cartConversion(list))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Unknown report"})
+	}
+}
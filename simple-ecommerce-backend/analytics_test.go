@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAnalyticsRevenueIsNetOfRefunds(t *testing.T) {
+	monday := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	list := []Order{
+		{ID: "o1", UserID: "u1", TotalAmount: 100, CreatedAt: monday, Items: []OrderItem{{ProductID: "p1", Price: 100, Quantity: 1}}},
+		{ID: "o2", UserID: "u1", TotalAmount: 60, RefundedAmount: 60, Status: "refunded", CreatedAt: monday.Add(time.Hour), Items: []OrderItem{{ProductID: "p2", Price: 30, Quantity: 2}}},
+		{ID: "o3", UserID: "u2", TotalAmount: 80.5, RefundedAmount: 20.25, Status: "partially_refunded", CreatedAt: monday.AddDate(0, 0, 8), Items: []OrderItem{{ProductID: "p1", Price: 80.5, Quantity: 1}}},
+	}
+
+	summary := summarizeSales(list, monday, monday.AddDate(0, 1, 0))
+	if summary.Revenue != 160.25 || summary.Refunded != 80.25 || summary.Orders != 3 || summary.Units != 4 {
+		t.Errorf("summary %+v, want revenue 160.25 after 80.25 refunded over 3 orders", summary)
+	}
+	if summary.AverageOrderValue != 53.42 {
+		t.Errorf("average order value %v, want 53.42", summary.AverageOrderValue)
+	}
+
+	points := revenueByPeriod(list, "week")
+	if len(points) != 2 || points[0].Period != "2026-W10" || points[0].Revenue != 100 || points[1].Revenue != 60.25 {
+		t.Errorf("weekly revenue %+v, want 100 then 60.25", points)
+	}
+
+	customers := salesByCustomer(t.Context(), list)
+	if len(customers) != 2 || customers[0].UserID != "u1" || customers[0].Revenue != 100 || customers[1].Revenue != 60.25 {
+		t.Errorf("customer revenue %+v, want u1 100, u2 60.25", customers)
+	}
+}
@@ -0,0 +1,216 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// defaultRetentionDays is how long archived products are kept before they
+// may be purged, unless PRODUCT_RETENTION_DAYS says otherwise
+const defaultRetentionDays = 90
+
+// PurgeResult lists the products removed, or that would be removed, by a purge
+type PurgeResult struct {
+	DryRun        bool      `json:"dryRun"`
+	RetentionDays int       `json:"retentionDays"`
+	Cutoff        time.Time `json:"cutoff"` // Products archived before this are purged
+	Purged        []string  `json:"purged"`
+}
+
+// retentionDays returns the configured retention period for archived products
+func retentionDays() int {
+	days, err := strconv.Atoi(os.Getenv("PRODUCT_RETENTION_DAYS"))
+	if err != nil || days < 0 {
+		return defaultRetentionDays
+	}
+	return days
+}
+
+// archiveProduct hides a product from the catalog and stops it being sold
+func archiveProduct(product *Product) {
+	now := time.Now()
+	product.ArchivedAt = &now
+	product.Version++
+}
+
+// purgedProductIDs remembers purged products so an import can't bring an
+// ID back and inherit what was recorded against it
+var purgedProductIDs = map[string]bool{}
+
+// purgeProduct removes an archived product for good, along with its images,
+// reviews, price rules, stock subscriptions and any cart or wishlist lines
+// still pointing at it. The inventory ledger is append-only and orders keep
+// their own copy of the name and price, so stock and order history stay as
+// they were; since the ID is never reused, nothing new is counted with them.
+func purgeProduct(ctx context.Context, productID string) {
+	_, span := startStoreSpan(ctx, "purgeProduct", attribute.String("product.id", productID))
+	defer span.End()
+
+	purgedProductIDs[productID] = true
+
+	var newProducts []Product
+	for _, product := range products {
+		if product.ID == productID {
+			deleteProductImages(product)
+			continue
+		}
+		newProducts = append(newProducts, product)
+	}
+	products = newProducts
+
+	for i := range carts {
+		items := []CartItem{}
+		for _, item := range carts[i].Items {
+			if item.ProductID != productID {
+				items = append(items, item)
+			}
+		}
+		carts[i].Items = items
+	}
+
+	for i := range wishlists {
+		items := []WishlistItem{}
+		for _, item := range wishlists[i].Items {
+			if item.ProductID != productID {
+				items = append(items, item)
+			}
+		}
+		wishlists[i].Items = items
+	}
+
+	var subscriptions []StockSubscription
+	for _, sub := range stockSubscriptions {
+		if sub.ProductID != productID {
+			subscriptions = append(subscriptions, sub)
+		}
+	}
+	stockSubscriptions = subscriptions
+
+	keptReviews := []Review{}
+	for _, review := range reviews {
+		if review.ProductID != productID {
+			keptReviews = append(keptReviews, review)
+		}
+	}
+	reviews = keptReviews
+
+	keptRules := []PriceRule{}
+	for _, rule := range priceRules {
+		if rule.ProductID != productID {
+			keptRules = append(keptRules, rule)
+		}
+	}
+	priceRules = keptRules
+}
+
+// handleAdminProducts routes the admin catalog endpoints
+func handleAdminProducts(w http.ResponseWriter, r *http.Request, pathParts []string) {
+	r, span := startSpan(r, "handleAdminProducts")
+	defer span.End()
+
+	switch pathParts[3] {
+	case "import":
+		importProducts(w, r)
+		return
+	case "export":
+		exportProducts(w, r)
+		return
+	case "archived":
+		listArchivedProducts(w, r)
+		return
+	case "purge":
+		purgeArchivedProducts(w, r)
+		return
+	}
+
+	// Handle actions on a single product
+	if len(pathParts) < 5 || r.Method != "POST" {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Not found"})
+		return
+	}
+
+	// Find product by ID
+	product := findProduct(r.Context(), pathParts[3])
+
+	// Product not found
+	if product == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Product not found"})
+		return
+	}
+
+	if product.ArchivedAt == nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Product is not archived"})
+		return
+	}
+
+	switch pathParts[4] {
+	case "restore":
+		product.ArchivedAt = nil
+		product.Version++
+		json.NewEncoder(w).Encode(product)
+	case "purge":
+		// Only once the retention period has passed
+		cutoff := time.Now().AddDate(0, 0, -retentionDays())
+		if product.ArchivedAt.After(cutoff) {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Product can't be purged until " + product.ArchivedAt.AddDate(0, 0, retentionDays()).Format("2006-01-02"),
+			})
+			return
+		}
+		purgeProduct(r.Context(), product.ID)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Product purged"})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Unknown action"})
+	}
+}
+
+// listArchivedProducts returns the archived products awaiting restore or purge
+func listArchivedProducts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	json.NewEncoder(w).Encode(archivedProducts(r.Context()))
+}
+
+// purgeArchivedProducts removes every product archived longer than the
+// retention period. With ?dryRun=true it only reports what would go.
+func purgeArchivedProducts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+	result := PurgeResult{
+		DryRun:        dryRun,
+		RetentionDays: retentionDays(),
+		Purged:        []string{},
+	}
+	result.Cutoff = time.Now().AddDate(0, 0, -result.RetentionDays)
+
+	for _, product := range archivedProducts(r.Context()) {
+		if product.ArchivedAt.Before(result.Cutoff) {
+			result.Purged = append(result.Purged, product.ID)
+		}
+	}
+	if !dryRun {
+		for _, id := range result.Purged {
+			purgeProduct(r.Context(), id)
+		}
+	}
+
+	json.NewEncoder(w).Encode(result)
+}
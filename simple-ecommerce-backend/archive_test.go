@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// adminProductAction posts to an /admin/products endpoint
+func adminProductAction(path string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", path, nil)
+	w := httptest.NewRecorder()
+	handleAdminProducts(w, r, strings.Split(r.URL.Path, "/"))
+	return w
+}
+
+func TestArchiveRestoreAndPurge(t *testing.T) {
+	keepStore(t)
+	keepSlice(t, &wishlists)
+	keepSlice(t, &reviews)
+	keepSlice(t, &stockSubscriptions)
+	keepValue(t, &purgedProductIDs)
+	purgedProductIDs = map[string]bool{}
+	t.Setenv("PRODUCT_RETENTION_DAYS", "")
+
+	// Deleting archives, and archived products drop out of the catalog
+	w := httptest.NewRecorder()
+	handleSingleProduct(w, httptest.NewRequest("DELETE", "/products/p1", nil), "p1")
+	if w.Code != 200 || findProduct(t.Context(), "p1").ArchivedAt == nil {
+		t.Fatalf("delete: status %d, product %+v", w.Code, findProduct(t.Context(), "p1"))
+	}
+	for _, product := range listProducts(t.Context(), false) {
+		if product.ID == "p1" {
+			t.Error("archived product still listed")
+		}
+	}
+
+	if w := adminProductAction("/admin/products/p1/restore"); w.Code != 200 || findProduct(t.Context(), "p1").ArchivedAt != nil {
+		t.Errorf("restore: status %d", w.Code)
+	}
+	if w := adminProductAction("/admin/products/p1/restore"); w.Code != 409 {
+		t.Errorf("restoring a live product: status %d, want 409", w.Code)
+	}
+
+	// p1 was archived just now, p2 long enough ago to purge
+	archiveProduct(findProduct(t.Context(), "p1"))
+	longAgo := time.Now().AddDate(0, 0, -defaultRetentionDays-1)
+	findProduct(t.Context(), "p2").ArchivedAt = &longAgo
+	carts = []Cart{{UserID: "u1", Items: []CartItem{{ProductID: "p2", Quantity: 1}, {ProductID: "p3", Quantity: 1}}}}
+	reviews = []Review{{ID: "rv1", ProductID: "p2"}}
+
+	if w := adminProductAction("/admin/products/p1/purge"); w.Code != 409 {
+		t.Errorf("purge within retention: status %d, want 409", w.Code)
+	}
+
+	var result PurgeResult
+	w = adminProductAction("/admin/products/purge?dryRun=true")
+	json.NewDecoder(w.Body).Decode(&result)
+	if !result.DryRun || len(result.Purged) != 1 || result.Purged[0] != "p2" || findProduct(t.Context(), "p2") == nil {
+		t.Errorf("dry run %+v removed or missed p2", result)
+	}
+
+	w = adminProductAction("/admin/products/purge")
+	json.NewDecoder(w.Body).Decode(&result)
+	if result.DryRun || len(result.Purged) != 1 || findProduct(t.Context(), "p2") != nil || findProduct(t.Context(), "p1") == nil {
+		t.Errorf("purge %+v, want only p2 gone", result)
+	}
+	if cart := findCart(t.Context(), "u1"); len(cart.Items) != 1 || cart.Items[0].ProductID != "p3" {
+		t.Errorf("cart after purge %+v, want only p3", cart.Items)
+	}
+	if len(reviews) != 0 || !purgedProductIDs["p2"] {
+		t.Errorf("purge left %d reviews, remembered = %v", len(reviews), purgedProductIDs["p2"])
+	}
+
+	// A shorter configured retention lets p1 go sooner
+	t.Setenv("PRODUCT_RETENTION_DAYS", "0")
+	yesterday := time.Now().AddDate(0, 0, -1)
+	findProduct(t.Context(), "p1").ArchivedAt = &yesterday
+	if w := adminProductAction("/admin/products/p1/purge"); w.Code != 200 || findProduct(t.Context(), "p1") != nil {
+		t.Errorf("purge after a configured retention: status %d", w.Code)
+	}
+}
+
+func TestRetentionDays(t *testing.T) {
+	for value, want := range map[string]int{"": defaultRetentionDays, "30": 30, "0": 0, "-1": defaultRetentionDays, "soon": defaultRetentionDays} {
+		t.Setenv("PRODUCT_RETENTION_DAYS", value)
+		if got := retentionDays(); got != want {
+			t.Errorf("PRODUCT_RETENTION_DAYS=%q: %d days, want %d", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrBlobNotFound is returned when a blob doesn't exist in the store
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobInfo describes a stored blob
+type BlobInfo struct {
+	Size         int64
+	LastModified time.Time
+}
+
+// BlobStore stores uploaded files by key. Keys are slash-separated paths
+// such as "products/p1/img1.jpg". Swap in another implementation (S3, GCS)
+// by assigning blobStore before serving requests.
+type BlobStore interface {
+	Put(key string, r io.Reader) error
+	Get(key string) (io.ReadCloser, BlobInfo, error)
+	Delete(key string) error
+}
+
+// LocalBlobStore keeps blobs as files under a directory
+type LocalBlobStore struct {
+	Dir string
+}
+
+// path maps a key to a file under the store's directory, refusing keys
+// that would escape it
+func (s LocalBlobStore) path(key string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(key))
+	if key == "" || filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", errors.New("invalid blob key")
+	}
+	return filepath.Join(s.Dir, clean), nil
+}
+
+// Put writes a blob, replacing any existing blob with the same key. The
+// data is written to a temporary file first so readers never see a
+// partial file.
+func (s LocalBlobStore) Put(key string, r io.Reader) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, r)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Get opens a blob for reading
+func (s LocalBlobStore) Get(key string) (io.ReadCloser, BlobInfo, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, BlobInfo{}, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, BlobInfo{}, ErrBlobNotFound
+	}
+	if err != nil {
+		return nil, BlobInfo{}, err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, BlobInfo{}, err
+	}
+	return file, BlobInfo{Size: stat.Size(), LastModified: stat.ModTime()}, nil
+}
+
+// Delete removes a blob; deleting a missing blob is not an error
+func (s LocalBlobStore) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// blobStore holds uploaded files. It is created on first use in the
+// configured upload directory, defaulting to one under the system temp dir.
+var (
+	blobStore     BlobStore
+	blobStoreErr  error
+	blobStoreOnce sync.Once
+)
+
+// getBlobStore returns the configured blob store
+func getBlobStore() (BlobStore, error) {
+	blobStoreOnce.Do(func() {
+		if blobStore != nil {
+			return
+		}
+		cfg, err := getConfig()
+		if err != nil {
+			blobStoreErr = err
+			return
+		}
+		dir := cfg.Storage.UploadDir
+		if dir == "" {
+			dir = filepath.Join(os.TempDir(), "ecommerce-uploads")
+		}
+		blobStoreErr = os.MkdirAll(dir, 0o755)
+		if blobStoreErr != nil {
+			return
+		}
+		blobStore = LocalBlobStore{Dir: dir}
+	})
+	return blobStore, blobStoreErr
+}
@@ -1,9 +1,13 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // CartItem represents an item in a user's cart
@@ -14,8 +18,9 @@ type CartItem struct {
 
 // Cart represents a user's shopping cart
 type Cart struct {
-	UserID string     `json:"userId"`
-	Items  []CartItem `json:"items"`
+	UserID     string     `json:"userId"`
+	Items      []CartItem `json:"items"`
+	CouponCode string     `json:"couponCode,omitempty"`
 }
 
 // CartRequest represents a request to add/update cart items
@@ -34,16 +39,31 @@ var carts = []Cart{
 	},
 }
 
+// cartRoutes lists the routes Handler serves, for CORS preflights and metrics
+var cartRoutes = routeTable{
+	"/carts/{id}":          {"GET", "POST", "PUT", "DELETE"},
+	"/carts/{id}/coupon":   {"POST", "DELETE"},
+	"/carts/{id}/shipping": {"GET"},
+}
+
 // Handler processes cart-related requests
 func Handler(w http.ResponseWriter, r *http.Request) {
-	// Set CORS headers
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+	// Tag the request with an ID and log it when done
+	w, r, done, ok := beginRequest(w, r, cartRoutes)
+	defer done()
 	
-	// Handle preflight requests
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
+	// Refuse to serve with an invalid configuration
+	if !ok {
+		return
+	}
+	
+	// Add CORS headers and answer preflight requests
+	if handleCORS(w, r, cartRoutes) {
+		return
+	}
+
+	// Turn away clients over the rate limit
+	if rateLimited(w, r) {
 		return
 	}
 
@@ -63,10 +83,22 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 	
 	userID := pathParts[2]
 	
+	// Handle coupon endpoint
+	if len(pathParts) > 3 && pathParts[3] == "coupon" {
+		handleCoupon(w, r, userID)
+		return
+	}
+	
+	// Handle shipping quote endpoint
+	if len(pathParts) > 3 && pathParts[3] == "shipping" {
+		getShippingQuotes(w, r, userID)
+		return
+	}
+	
 	// Handle different methods
 	switch r.Method {
 	case "GET":
-		getCart(w, userID)
+		getCart(w, r, userID)
 	case "POST":
 		addToCart(w, r, userID)
 	case "PUT":
@@ -79,30 +111,24 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 }
 
 // getCart returns the user's cart
-func getCart(w http.ResponseWriter, userID string) {
-	// Find cart by userID
-	var cart *Cart
-	for i := range carts {
-		if carts[i].UserID == userID {
-			cart = &carts[i]
-			break
-		}
-	}
+func getCart(w http.ResponseWriter, r *http.Request, userID string) {
+	r, span := startSpan(r, "getCart", attribute.String("user.id", userID))
+	defer span.End()
 	
-	// If cart doesn't exist, create an empty one
-	if cart == nil {
-		cart = &Cart{
-			UserID: userID,
-			Items:  []CartItem{},
-		}
-		carts = append(carts, *cart)
-	}
+	// Find cart by userID, creating an empty one if it doesn't exist
+	cart := findOrCreateCart(r.Context(), userID)
 	
-	json.NewEncoder(w).Encode(cart)
+	// Price the cart as of now so sales that start or end show up;
+	// coupon problems are reported at checkout
+	summary, _ := summarizeCart(*cart)
+	json.NewEncoder(w).Encode(summary)
 }
 
 // addToCart adds an item to the cart
 func addToCart(w http.ResponseWriter, r *http.Request, userID string) {
+	r, span := startSpan(r, "addToCart", attribute.String("user.id", userID))
+	defer span.End()
+	
 	var req CartRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
@@ -110,40 +136,42 @@ func addToCart(w http.ResponseWriter, r *http.Request, userID string) {
 		return
 	}
 	
-	// Validate quantity
-	if req.Quantity <= 0 {
+	cart, err := addCartItem(r.Context(), userID, req.ProductID, req.Quantity)
+	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Quantity must be positive"})
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
 		return
 	}
 	
-	// Find cart by userID
-	var cart *Cart
-	var cartIndex int
-	for i := range carts {
-		if carts[i].UserID == userID {
-			cart = &carts[i]
-			cartIndex = i
-			break
-		}
+	json.NewEncoder(w).Encode(cart)
+}
+
+// addCartItem validates and adds a quantity of a product to the user's cart.
+// Every path that puts items in a cart goes through here.
+func addCartItem(ctx context.Context, userID, productID string, quantity int) (*Cart, error) {
+	// Validate quantity
+	if quantity <= 0 {
+		return nil, errors.New("Quantity must be positive")
 	}
 	
-	// If cart doesn't exist, create a new one
-	if cart == nil {
-		cart = &Cart{
-			UserID: userID,
-			Items:  []CartItem{},
-		}
-		carts = append(carts, *cart)
-		cartIndex = len(carts) - 1
+	// Validate product
+	product := findProduct(ctx, productID)
+	if product == nil {
+		return nil, errors.New("Product not found")
+	}
+	if product.ArchivedAt != nil {
+		return nil, errors.New("Product is no longer available")
 	}
 	
+	// Find cart by userID, creating a new one if it doesn't exist
+	cart := findOrCreateCart(ctx, userID)
+	
 	// Check if product already in cart
-	var found bool
+	found := false
 	for i := range cart.Items {
-		if cart.Items[i].ProductID == req.ProductID {
+		if cart.Items[i].ProductID == productID {
 			// Update quantity
-			cart.Items[i].Quantity += req.Quantity
+			cart.Items[i].Quantity += quantity
 			found = true
 			break
 		}
@@ -152,19 +180,19 @@ func addToCart(w http.ResponseWriter, r *http.Request, userID string) {
 	// If product not in cart, add it
 	if !found {
 		cart.Items = append(cart.Items, CartItem{
-			ProductID: req.ProductID,
-			Quantity:  req.Quantity,
+			ProductID: productID,
+			Quantity:  quantity,
 		})
 	}
 	
-	// Update cart in storage
-	carts[cartIndex] = *cart
-	
-	json.NewEncoder(w).Encode(cart)
+	return cart, nil
 }
 
 // updateCart updates the quantity of an item in the cart
 func updateCart(w http.ResponseWriter, r *http.Request, userID string) {
+	r, span := startSpan(r, "updateCart", attribute.String("user.id", userID))
+	defer span.End()
+	
 	var req CartRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
@@ -173,15 +201,7 @@ func updateCart(w http.ResponseWriter, r *http.Request, userID string) {
 	}
 	
 	// Find cart by userID
-	var cart *Cart
-	var cartIndex int
-	for i := range carts {
-		if carts[i].UserID == userID {
-			cart = &carts[i]
-			cartIndex = i
-			break
-		}
-	}
+	cart := findCart(r.Context(), userID)
 	
 	// Cart not found
 	if cart == nil {
@@ -213,14 +233,14 @@ func updateCart(w http.ResponseWriter, r *http.Request, userID string) {
 		return
 	}
 	
-	// Update cart in storage
-	carts[cartIndex] = *cart
-	
 	json.NewEncoder(w).Encode(cart)
 }
 
 // removeFromCart removes an item from the cart
 func removeFromCart(w http.ResponseWriter, r *http.Request, userID string) {
+	r, span := startSpan(r, "removeFromCart", attribute.String("user.id", userID))
+	defer span.End()
+	
 	// Extract product ID from query parameters
 	productID := r.URL.Query().Get("productId")
 	if productID == "" {
@@ -230,15 +250,7 @@ func removeFromCart(w http.ResponseWriter, r *http.Request, userID string) {
 	}
 	
 	// Find cart by userID
-	var cart *Cart
-	var cartIndex int
-	for i := range carts {
-		if carts[i].UserID == userID {
-			cart = &carts[i]
-			cartIndex = i
-			break
-		}
-	}
+	cart := findCart(r.Context(), userID)
 	
 	// Cart not found
 	if cart == nil {
@@ -265,8 +277,5 @@ func removeFromCart(w http.ResponseWriter, r *http.Request, userID string) {
 		return
 	}
 	
-	// Update cart in storage
-	carts[cartIndex] = *cart
-	
 	json.NewEncoder(w).Encode(cart)
 }
@@ -0,0 +1,599 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxImportBytes limits the size of an uploaded catalog file
+const maxImportBytes = 32 << 20
+
+// catalogColumns are the CSV columns for import and export, in export order
+var catalogColumns = []string{
+	"id", "sku", "name", "description", "price", "imageUrl", "stock",
+	"lowStockThreshold", "taxCategory", "weightKg", "lengthCm", "widthCm", "heightCm",
+}
+
+// ProductRow is one product in an import file. Nil fields were blank or
+// missing and keep their current value when updating an existing product.
+type ProductRow struct {
+	ID                *string     `json:"id"`
+	SKU               *string     `json:"sku"`
+	Name              *string     `json:"name"`
+	Description       *string     `json:"description"`
+	Price             *float64    `json:"price"`
+	ImageURL          *string     `json:"imageUrl"`
+	Stock             *int        `json:"stock"` // Opening stock; ignored when updating
+	LowStockThreshold *int        `json:"lowStockThreshold"`
+	TaxCategory       *string     `json:"taxCategory"`
+	WeightKg          *float64    `json:"weightKg"`
+	Dimensions        *Dimensions `json:"dimensions"`
+}
+
+// ImportRowError lists the problems with one row of an import file
+type ImportRowError struct {
+	Row    int      `json:"row"` // Line number in the file; the CSV header is row 1
+	ID     string   `json:"id,omitempty"`
+	SKU    string   `json:"sku,omitempty"`
+	Errors []string `json:"errors"`
+}
+
+// ImportResult summarizes a bulk import
+type ImportResult struct {
+	DryRun  bool             `json:"dryRun"`
+	Rows    int              `json:"rows"`
+	Created int              `json:"created"`
+	Updated int              `json:"updated"`
+	Failed  int              `json:"failed"`
+	Errors  []ImportRowError `json:"errors"`
+}
+
+// catalogFormat picks csv or jsonl from the format parameter or Content-Type
+func catalogFormat(r *http.Request, contentType string) string {
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		if strings.Contains(contentType, "ndjson") || strings.Contains(contentType, "jsonl") {
+			format = "jsonl"
+		} else {
+			format = "csv"
+		}
+	}
+	if format == "ndjson" {
+		format = "jsonl"
+	}
+	return format
+}
+
+// lastProductNumber is the highest "p" number handed out or imported. It
+// only ever goes up, so a purged product's ID, and with it the ledger
+// entries and order history recorded against it, never passes to a new
+// product.
+var lastProductNumber = highestProductNumber()
+
+// highestProductNumber finds the highest "p" number in the catalog
+func highestProductNumber() int {
+	highest := 0
+	for _, product := range products {
+		n, err := strconv.Atoi(strings.TrimPrefix(product.ID, "p"))
+		if err == nil && n > highest {
+			highest = n
+		}
+	}
+	return highest
+}
+
+// nextProductID returns an ID no product has ever had
+func nextProductID() string {
+	lastProductNumber++
+	return "p" + strconv.Itoa(lastProductNumber)
+}
+
+// noteProductID keeps lastProductNumber ahead of an ID chosen by an import
+func noteProductID(id string) {
+	n, err := strconv.Atoi(strings.TrimPrefix(id, "p"))
+	if err == nil && n > lastProductNumber {
+		lastProductNumber = n
+	}
+}
+
+// parseCSVRow converts a CSV record to a row, using the header to find columns
+func parseCSVRow(header map[string]int, record []string) (ProductRow, []string) {
+	var row ProductRow
+	var problems []string
+
+	cell := func(column string) (string, bool) {
+		i, ok := header[column]
+		if !ok || i >= len(record) {
+			return "", false
+		}
+		value := strings.TrimSpace(record[i])
+		return value, value != ""
+	}
+	text := func(column string) *string {
+		if value, ok := cell(column); ok {
+			value = fromSpreadsheetCell(value)
+			return &value
+		}
+		return nil
+	}
+	decimal := func(column string) *float64 {
+		value, ok := cell(column)
+		if !ok {
+			return nil
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			problems = append(problems, column+" must be a number")
+			return nil
+		}
+		return &f
+	}
+	integer := func(column string) *int {
+		value, ok := cell(column)
+		if !ok {
+			return nil
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			problems = append(problems, column+" must be a whole number")
+			return nil
+		}
+		return &n
+	}
+
+	row.ID = text("id")
+	row.SKU = text("sku")
+	row.Name = text("name")
+	row.Description = text("description")
+	row.Price = decimal("price")
+	row.ImageURL = text("imageUrl")
+	row.Stock = integer("stock")
+	row.LowStockThreshold = integer("lowStockThreshold")
+	row.TaxCategory = text("taxCategory")
+	row.WeightKg = decimal("weightKg")
+
+	length, width, height := decimal("lengthCm"), decimal("widthCm"), decimal("heightCm")
+	if length != nil && width != nil && height != nil {
+		row.Dimensions = &Dimensions{LengthCm: *length, WidthCm: *width, HeightCm: *height}
+	} else if length != nil || width != nil || height != nil {
+		problems = append(problems, "lengthCm, widthCm and heightCm must be given together")
+	}
+
+	return row, problems
+}
+
+// findImportTarget finds the product a row updates, by ID first and then
+// SKU. It returns -1 when the row is a new product.
+func findImportTarget(row ProductRow) (int, []string) {
+	if row.ID != nil {
+		if row.SKU != nil && skuTaken(*row.SKU, *row.ID) {
+			return -1, []string{"SKU " + *row.SKU + " belongs to another product"}
+		}
+		for i := range products {
+			if products[i].ID == *row.ID {
+				return i, nil
+			}
+		}
+		if purgedProductIDs[*row.ID] {
+			return -1, []string{"ID " + *row.ID + " belonged to a purged product"}
+		}
+		return -1, nil
+	}
+	if row.SKU != nil {
+		for i := range products {
+			if strings.EqualFold(products[i].SKU, *row.SKU) {
+				return i, nil
+			}
+		}
+	}
+	return -1, nil
+}
+
+// skuTaken reports whether another product already uses a SKU
+func skuTaken(sku, exceptID string) bool {
+	for _, product := range products {
+		if product.ID != exceptID && strings.EqualFold(product.SKU, sku) {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeProductRow applies the non-blank fields of a row to a product
+func mergeProductRow(product Product, row ProductRow) Product {
+	if row.SKU != nil {
+		product.SKU = *row.SKU
+	}
+	if row.Name != nil {
+		product.Name = *row.Name
+	}
+	if row.Description != nil {
+		product.Description = *row.Description
+	}
+	if row.Price != nil {
+		product.Price = *row.Price
+	}
+	if row.ImageURL != nil && len(product.Images) == 0 {
+		product.ImageURL = *row.ImageURL
+	}
+	if row.Stock != nil {
+		product.Stock = *row.Stock
+	}
+	if row.LowStockThreshold != nil {
+		product.LowStockThreshold = *row.LowStockThreshold
+	}
+	if row.TaxCategory != nil {
+		product.TaxCategory = *row.TaxCategory
+	}
+	if row.WeightKg != nil {
+		product.WeightKg = *row.WeightKg
+	}
+	if row.Dimensions != nil {
+		product.Dimensions = *row.Dimensions
+	}
+	return product
+}
+
+// validateProduct checks the fields a product must have to be sold
+func validateProduct(product Product) []string {
+	var problems []string
+	if strings.TrimSpace(product.Name) == "" {
+		problems = append(problems, "name is required")
+	}
+	if product.Price <= 0 {
+		problems = append(problems, "price must be positive")
+	}
+	if product.Stock < 0 {
+		problems = append(problems, "stock cannot be negative")
+	}
+	if product.LowStockThreshold < 0 {
+		problems = append(problems, "lowStockThreshold cannot be negative")
+	}
+	switch product.TaxCategory {
+	case "", TaxStandard, TaxReduced, TaxExempt:
+	default:
+		problems = append(problems, "taxCategory must be standard, reduced or exempt")
+	}
+	if product.WeightKg < 0 {
+		problems = append(problems, "weightKg cannot be negative")
+	}
+	if product.Dimensions.LengthCm < 0 || product.Dimensions.WidthCm < 0 || product.Dimensions.HeightCm < 0 {
+		problems = append(problems, "dimensions cannot be negative")
+	}
+	return problems
+}
+
+// productImporter applies rows one at a time, collecting per-row errors
+type productImporter struct {
+	ctx    context.Context // The import request's, so stock changes join its trace
+	result ImportResult
+	seen   map[string]int // ID or SKU -> first row that used it
+}
+
+// apply validates one row and, unless this is a dry run, upserts it
+func (im *productImporter) apply(rowNumber int, row ProductRow, problems []string) {
+	im.result.Rows++
+
+	// Blank keys mean "not given", whichever format the row came from
+	if row.ID != nil && strings.TrimSpace(*row.ID) == "" {
+		row.ID = nil
+	}
+	if row.SKU != nil && strings.TrimSpace(*row.SKU) == "" {
+		row.SKU = nil
+	}
+
+	rowErr := ImportRowError{Row: rowNumber, Errors: problems}
+	if row.ID != nil {
+		rowErr.ID = *row.ID
+	}
+	if row.SKU != nil {
+		rowErr.SKU = *row.SKU
+	}
+	fail := func() {
+		im.result.Failed++
+		im.result.Errors = append(im.result.Errors, rowErr)
+	}
+
+	if len(rowErr.Errors) > 0 {
+		fail()
+		return
+	}
+
+	// A file may only mention each product once
+	for _, key := range []string{rowErr.ID, strings.ToLower(rowErr.SKU)} {
+		if key == "" {
+			continue
+		}
+		if first, ok := im.seen[key]; ok {
+			rowErr.Errors = append(rowErr.Errors, fmt.Sprintf("Duplicate of row %d", first))
+			fail()
+			return
+		}
+	}
+
+	index, problems := findImportTarget(row)
+	if len(problems) > 0 {
+		rowErr.Errors = problems
+		fail()
+		return
+	}
+
+	var before Product
+	if index >= 0 {
+		before = products[index]
+	}
+	after := mergeProductRow(before, row)
+
+	// Stock only opens a new product. An existing product's stock changes
+	// through the inventory ledger, so re-importing an old export can't
+	// undo the sales made since.
+	if index >= 0 {
+		after.Stock = before.Stock
+	}
+
+	problems = validateProduct(after)
+	if len(problems) > 0 {
+		rowErr.Errors = problems
+		fail()
+		return
+	}
+
+	for _, key := range []string{rowErr.ID, strings.ToLower(rowErr.SKU)} {
+		if key != "" {
+			im.seen[key] = rowNumber
+		}
+	}
+
+	if index >= 0 {
+		im.result.Updated++
+	} else {
+		im.result.Created++
+	}
+	if im.result.DryRun {
+		return
+	}
+
+	after.Version = before.Version + 1
+	if index >= 0 {
+		products[index] = after
+		return
+	}
+
+	after.ID = nextProductID()
+	if row.ID != nil {
+		after.ID = *row.ID
+		noteProductID(after.ID)
+	}
+	after.RatingAverage = 0
+	after.RatingCount = 0
+	after.Images = nil
+
+	// Opening stock goes through the inventory ledger
+	openingStock := after.Stock
+	after.Stock = 0
+	products = append(products, after)
+	if openingStock > 0 {
+		recordInventory(im.ctx, after.ID, InventoryRestock, openingStock, "Opening stock", "")
+	}
+}
+
+// importCSV reads products from a CSV file with a header row
+func (im *productImporter) importCSV(body io.Reader) error {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	headerRow, err := reader.Read()
+	if err == io.EOF {
+		return errors.New("File is empty")
+	}
+	if err != nil {
+		return err
+	}
+	header := map[string]int{}
+	for i, column := range headerRow {
+		header[strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))] = i
+	}
+	if _, ok := header["id"]; !ok {
+		if _, ok := header["sku"]; !ok {
+			return errors.New("Header must include an id or sku column")
+		}
+	}
+
+	rowNumber := 1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		rowNumber++
+		if err != nil {
+			// A malformed line is reported against its row; keep going
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				im.apply(rowNumber, ProductRow{}, []string{parseErr.Err.Error()})
+				continue
+			}
+			return err
+		}
+		row, problems := parseCSVRow(header, record)
+		im.apply(rowNumber, row, problems)
+	}
+}
+
+// importJSONL reads one JSON product per line, skipping blank lines
+func (im *productImporter) importJSONL(body io.Reader) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+
+	rowNumber := 0
+	for scanner.Scan() {
+		rowNumber++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var row ProductRow
+		err := json.Unmarshal(line, &row)
+		if err != nil {
+			problem := "Invalid JSON: " + err.Error()
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) {
+				switch typeErr.Type.String() {
+				case "float64":
+					problem = typeErr.Field + " must be a number"
+				case "int":
+					problem = typeErr.Field + " must be a whole number"
+				default:
+					problem = typeErr.Field + " must be a " + typeErr.Type.String()
+				}
+			}
+			im.apply(rowNumber, row, []string{problem})
+			continue
+		}
+		im.apply(rowNumber, row, nil)
+	}
+	return scanner.Err()
+}
+
+// importProducts upserts products from a CSV or JSONL upload. Bad rows are
+// reported and skipped; with ?dryRun=true nothing is changed.
+func importProducts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+	importer := &productImporter{
+		ctx:    r.Context(),
+		result: ImportResult{DryRun: dryRun, Errors: []ImportRowError{}},
+		seen:   map[string]int{},
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+
+	var err error
+	switch catalogFormat(r, r.Header.Get("Content-Type")) {
+	case "csv":
+		err = importer.importCSV(r.Body)
+	case "jsonl":
+		err = importer.importJSONL(r.Body)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Format must be csv or jsonl"})
+		return
+	}
+
+	// File-level problems stop the import; rows already applied stay applied
+	if err != nil {
+		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":  "Import stopped: " + err.Error(),
+			"result": importer.result,
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(importer.result)
+}
+
+// exportProducts streams the catalog as CSV or JSONL
+func exportProducts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Archived products are left out unless asked for
+	includeArchived := r.URL.Query().Get("includeArchived") == "true"
+	list := []Product{}
+	for _, product := range products {
+		if product.ArchivedAt == nil || includeArchived {
+			list = append(list, product)
+		}
+	}
+
+	switch catalogFormat(r, r.Header.Get("Accept")) {
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="products.csv"`)
+		writeProductsCSV(w, list)
+	case "jsonl":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="products.jsonl"`)
+		encoder := json.NewEncoder(w)
+		for _, product := range list {
+			encoder.Encode(product)
+		}
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Format must be csv or jsonl"})
+	}
+}
+
+// spreadsheetCell guards text bound for a CSV file against formula
+// injection: a cell starting with =, +, - or @ would run as a formula when
+// the file is opened in a spreadsheet, so it gets a leading quote
+func spreadsheetCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// fromSpreadsheetCell undoes spreadsheetCell, so an export imports as it was
+func fromSpreadsheetCell(value string) string {
+	if len(value) > 1 && value[0] == '\'' && strings.ContainsRune("=+-@", rune(value[1])) {
+		return value[1:]
+	}
+	return value
+}
+
+// writeProductsCSV writes products in the same columns the importer reads
+func writeProductsCSV(w io.Writer, list []Product) {
+	out := csv.NewWriter(w)
+	out.Write(catalogColumns)
+
+	decimal := func(f float64) string {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	for i, product := range list {
+		out.Write([]string{
+			spreadsheetCell(product.ID),
+			spreadsheetCell(product.SKU),
+			spreadsheetCell(product.Name),
+			spreadsheetCell(product.Description),
+			decimal(product.Price),
+			spreadsheetCell(product.ImageURL),
+			strconv.Itoa(product.Stock),
+			strconv.Itoa(product.LowStockThreshold),
+			spreadsheetCell(product.TaxCategory),
+			decimal(product.WeightKg),
+			decimal(product.Dimensions.LengthCm),
+			decimal(product.Dimensions.WidthCm),
+			decimal(product.Dimensions.HeightCm),
+		})
+
+		// Flush periodically so large catalogs stream instead of buffering
+		if i%500 == 499 {
+			out.Flush()
+		}
+	}
+	out.Flush()
+}
@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// runImport posts a catalog file to the import endpoint
+func runImport(t *testing.T, query, contentType, body string) ImportResult {
+	t.Helper()
+	r := httptest.NewRequest("POST", "/admin/products/import"+query, strings.NewReader(body))
+	r.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	importProducts(w, r)
+	if w.Code != 200 {
+		t.Fatalf("import: status %d: %s", w.Code, w.Body)
+	}
+	var result ImportResult
+	json.NewDecoder(w.Body).Decode(&result)
+	return result
+}
+
+// rowsWithErrors lists the rows an import rejected
+func rowsWithErrors(result ImportResult) []int {
+	rows := []int{}
+	for _, rowErr := range result.Errors {
+		rows = append(rows, rowErr.Row)
+	}
+	return rows
+}
+
+func TestParseCSVRow(t *testing.T) {
+	header := map[string]int{"sku": 0, "name": 1, "price": 2, "stock": 3, "lengthCm": 4}
+	row, problems := parseCSVRow(header, []string{" KB-1 ", "'=Keyboard", "12.5", "", "3"})
+
+	if *row.SKU != "KB-1" || *row.Name != "=Keyboard" || *row.Price != 12.5 {
+		t.Errorf("row = %+v", row)
+	}
+	if row.Stock != nil || row.Description != nil {
+		t.Error("blank and missing cells should be nil")
+	}
+	want := []string{"lengthCm, widthCm and heightCm must be given together"}
+	if !reflect.DeepEqual(problems, want) {
+		t.Errorf("problems = %q, want %q", problems, want)
+	}
+
+	_, problems = parseCSVRow(header, []string{"KB-1", "Keyboard", "cheap", "1.5"})
+	want = []string{"price must be a number", "stock must be a whole number"}
+	if !reflect.DeepEqual(problems, want) {
+		t.Errorf("problems = %q, want %q", problems, want)
+	}
+}
+
+func TestImportCSV(t *testing.T) {
+	keepStore(t)
+	file := "sku,name,price,stock\n" +
+		"kb-mech-01,Keyboard II,,999\n" + // Upsert by SKU, any case; stock ignored
+		"NEW-1,Cable,9.99,7\n" + // New product with opening stock
+		"NEW-2,Bad,free,1\n" + // Unparseable price
+		"KB-MECH-01,Again,1,1\n" + // Same product twice
+		"NEW-3,,5,1\n" // Missing name
+
+	dry := runImport(t, "?dryRun=true", "text/csv", file)
+	if !dry.DryRun || dry.Created != 1 || dry.Updated != 1 || dry.Failed != 3 {
+		t.Errorf("dry run = %+v", dry)
+	}
+	if len(products) != 3 || products[0].Name != "Mechanical Keyboard" {
+		t.Fatal("dry run changed the catalog")
+	}
+
+	result := runImport(t, "", "text/csv", file)
+	if result.Rows != 5 || result.Created != 1 || result.Updated != 1 || result.Failed != 3 {
+		t.Errorf("result = %+v", result)
+	}
+	if rows := rowsWithErrors(result); !reflect.DeepEqual(rows, []int{4, 5, 6}) {
+		t.Errorf("rows with errors = %v, want [4 5 6]", rows)
+	}
+
+	keyboard := findProduct(context.Background(), "p1")
+	if keyboard.Name != "Keyboard II" || keyboard.Price != 129.99 || keyboard.Stock != 50 || keyboard.Version != 2 {
+		t.Errorf("updated product = %+v", *keyboard)
+	}
+	cable := products[len(products)-1]
+	if cable.SKU != "NEW-1" || cable.Stock != 7 || stockFor(cable.ID) != 7 {
+		t.Errorf("created product = %+v, ledger stock %d", cable, stockFor(cable.ID))
+	}
+}
+
+func TestImportJSONL(t *testing.T) {
+	keepStore(t)
+	file := `{"id":"p2","price":44.99}` + "\n\n" +
+		`{"sku":"NEW-1","name":"Pad","price":"cheap"}` + "\n" +
+		`{"id":"p50","sku":"NEW-2","name":"Dock","price":99,"stock":2}` + "\n" +
+		`not json` + "\n"
+
+	result := runImport(t, "", "application/x-ndjson", file)
+	if result.Created != 1 || result.Updated != 1 || result.Failed != 2 {
+		t.Errorf("result = %+v", result)
+	}
+	if rows := rowsWithErrors(result); !reflect.DeepEqual(rows, []int{3, 5}) {
+		t.Errorf("rows with errors = %v, want [3 5]", rows)
+	}
+	if result.Errors[0].Errors[0] != "price must be a number" {
+		t.Errorf("type error reported as %q", result.Errors[0].Errors[0])
+	}
+	if dock := findProduct(context.Background(), "p50"); dock == nil || dock.Stock != 2 {
+		t.Errorf("product imported with its own ID: %+v", dock)
+	}
+	if nextProductID() != "p51" {
+		t.Error("an imported ID can be handed out again")
+	}
+}
+
+func TestReimportKeepsSales(t *testing.T) {
+	keepStore(t)
+	var export bytes.Buffer
+	writeProductsCSV(&export, products)
+
+	recordInventory(context.Background(), "p1", InventorySale, -5, "", "o9")
+	runImport(t, "", "text/csv", export.String())
+
+	if stock := findProduct(context.Background(), "p1").Stock; stock != 45 || stockFor("p1") != 45 {
+		t.Errorf("stock after re-import = %d, ledger %d; want 45", stock, stockFor("p1"))
+	}
+}
+
+func TestExportGuardsFormulas(t *testing.T) {
+	keepStore(t)
+	products[0].Name = "=HYPERLINK(\"http://evil\")"
+	products[0].Description = "-1 for this"
+	products[1].Name = "@SUM(A1)"
+
+	var export bytes.Buffer
+	writeProductsCSV(&export, products)
+	records, err := csv.NewReader(bytes.NewReader(export.Bytes())).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range records[1:] {
+		for _, cell := range record[:4] {
+			if cell != "" && strings.ContainsRune("=+-@", rune(cell[0])) {
+				t.Errorf("cell %q would run as a formula", cell)
+			}
+		}
+	}
+
+	// Re-importing the export restores the original text
+	runImport(t, "", "text/csv", export.String())
+	if products[0].Name != "=HYPERLINK(\"http://evil\")" || products[0].Description != "-1 for this" {
+		t.Errorf("round trip changed the product: %q, %q", products[0].Name, products[0].Description)
+	}
+}
@@ -0,0 +1,194 @@
+// Command catalog bulk imports and exports products through the admin API.
+//
+// The store keeps its catalog in the running server's memory, so this tool
+// talks to the server over HTTP rather than touching data directly.
+//
+//	catalog import [-server URL] [-format csv|jsonl] [-dry-run] products.csv
+//	catalog export [-server URL] [-format csv|jsonl] [-o products.csv]
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// importResult mirrors the server's import summary
+type importResult struct {
+	DryRun  bool `json:"dryRun"`
+	Rows    int  `json:"rows"`
+	Created int  `json:"created"`
+	Updated int  `json:"updated"`
+	Failed  int  `json:"failed"`
+	Errors  []struct {
+		Row    int      `json:"row"`
+		ID     string   `json:"id"`
+		SKU    string   `json:"sku"`
+		Errors []string `json:"errors"`
+	} `json:"errors"`
+}
+
+var client = &http.Client{Timeout: 5 * time.Minute}
+
+// errRowsFailed means the import finished but some rows were rejected
+var errRowsFailed = errors.New("some rows failed")
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "import":
+		err = runImport(os.Args[2:])
+	case "export":
+		err = runExport(os.Args[2:])
+	default:
+		usage()
+	}
+	if err == errRowsFailed {
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "catalog:", err)
+		os.Exit(2)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: catalog import [-server URL] [-format csv|jsonl] [-dry-run] FILE")
+	fmt.Fprintln(os.Stderr, "       catalog export [-server URL] [-format csv|jsonl] [-o FILE]")
+	os.Exit(2)
+}
+
+// defaultServer is the admin API base URL unless -server is given
+func defaultServer() string {
+	if server := os.Getenv("ECOMMERCE_URL"); server != "" {
+		return server
+	}
+	return "http://localhost:3000"
+}
+
+// formatFromName guesses csv or jsonl from a file extension
+func formatFromName(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jsonl", ".ndjson":
+		return "jsonl"
+	default:
+		return "csv"
+	}
+}
+
+func runImport(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	server := flags.String("server", defaultServer(), "base URL of the store")
+	format := flags.String("format", "", "file format, csv or jsonl (default from extension)")
+	dryRun := flags.Bool("dry-run", false, "validate the file without changing the catalog")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		usage()
+	}
+
+	path := flags.Arg(0)
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if *format == "" {
+		*format = formatFromName(path)
+	}
+	query := url.Values{"format": {*format}}
+	if *dryRun {
+		query.Set("dryRun", "true")
+	}
+
+	contentType := "text/csv"
+	if *format == "jsonl" {
+		contentType = "application/x-ndjson"
+	}
+	resp, err := client.Post(strings.TrimRight(*server, "/")+"/admin/products/import?"+query.Encode(), contentType, file)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var result importResult
+	err = json.Unmarshal(body, &result)
+	if err != nil {
+		return err
+	}
+
+	for _, rowErr := range result.Errors {
+		key := rowErr.ID
+		if key == "" {
+			key = rowErr.SKU
+		}
+		fmt.Fprintf(os.Stderr, "row %d %s: %s\n", rowErr.Row, key, strings.Join(rowErr.Errors, "; "))
+	}
+
+	verb := "imported"
+	if result.DryRun {
+		verb = "checked (dry run)"
+	}
+	fmt.Printf("%d rows %s: %d created, %d updated, %d failed\n",
+		result.Rows, verb, result.Created, result.Updated, result.Failed)
+
+	if result.Failed > 0 {
+		return errRowsFailed
+	}
+	return nil
+}
+
+func runExport(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	server := flags.String("server", defaultServer(), "base URL of the store")
+	format := flags.String("format", "", "file format, csv or jsonl (default from -o, else csv)")
+	output := flags.String("o", "", "write to this file instead of stdout")
+	flags.Parse(args)
+
+	if *format == "" {
+		*format = formatFromName(*output)
+	}
+
+	resp, err := client.Get(strings.TrimRight(*server, "/") + "/admin/products/export?format=" + url.QueryEscape(*format))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("server returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		out, err = os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+
+	_, err = io.Copy(out, resp.Body)
+	return err
+}
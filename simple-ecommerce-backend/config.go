@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"sync"
+
+	"learn_go/config"
+)
+
+// appConfig holds the store's settings, loaded on first use from
+// CONFIG_FILE and the environment. There is no command line when running
+// as serverless functions, so flags don't apply here.
+var (
+	appConfig     config.Config
+	appConfigErr  error
+	appConfigOnce sync.Once
+)
+
+// getConfig returns the validated configuration. An invalid configuration
+// is logged once and fails the readiness check.
+func getConfig() (config.Config, error) {
+	appConfigOnce.Do(func() {
+		appConfig, appConfigErr = config.Load(nil)
+		if appConfigErr != nil {
+			logger.Error("invalid configuration", "error", appConfigErr)
+			return
+		}
+		logLevel.UnmarshalText([]byte(appConfig.LogLevel))
+	})
+	return appConfig, appConfigErr
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"errors"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"learn_go/config"
+)
+
+// useConfig makes getConfig return cfg and err until the test ends, and
+// rebuilds the token key and rate limiter from them
+func useConfig(t *testing.T, cfg config.Config, err error) {
+	getConfig() // Load now so the real load can't overwrite ours later
+	savedConfig, savedErr := appConfig, appConfigErr
+	appConfig, appConfigErr = cfg, err
+
+	reset := func() {
+		tokenKeyOnce = sync.Once{}
+		tokenKey = nil
+		rateLimiterOnce = sync.Once{}
+		rateLimiter, rateLimiterErr = nil, nil
+	}
+	reset()
+	t.Cleanup(func() {
+		appConfig, appConfigErr = savedConfig, savedErr
+		reset()
+	})
+}
+
+func TestInvalidConfigFailsClosed(t *testing.T) {
+	useConfig(t, config.Config{}, errors.New("token_secret is required in production"))
+
+	w, r, done, ok := beginRequest(httptest.NewRecorder(), httptest.NewRequest("GET", "/products", nil), productRoutes)
+	done()
+	if ok || w.(*statusRecorder).status != 503 {
+		t.Errorf("beginRequest: ok %v, status %d; want false, 503", ok, w.(*statusRecorder).status)
+	}
+
+	r.Header.Set("Authorization", "Bearer "+issueToken("u1", time.Now()))
+	if tokenUserID(r) != "" {
+		t.Error("a token was accepted without a key")
+	}
+
+	recorder := httptest.NewRecorder()
+	if !rateLimited(recorder, r) || recorder.Code != 503 {
+		t.Errorf("rateLimited let the request through: status %d", recorder.Code)
+	}
+}
+
+func TestTokenKey(t *testing.T) {
+	secret := config.Default()
+	secret.Secrets.TokenSecret = config.Secret("a-secret-long-enough-for-production")
+	production := config.Default()
+	production.Environment = "production"
+
+	tests := []struct {
+		name string
+		cfg  config.Config
+		want string // "random", "none" or the key
+	}{
+		{"configured secret", secret, "a-secret-long-enough-for-production"},
+		{"development without a secret", config.Default(), "random"},
+		{"production without a secret", production, "none"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			useConfig(t, tc.cfg, nil)
+			key := getTokenKey()
+			switch tc.want {
+			case "random":
+				if len(key) != 32 {
+					t.Errorf("key = %q, want 32 random bytes", key)
+				}
+			case "none":
+				if key != nil {
+					t.Errorf("key = %q, want none", key)
+				}
+			default:
+				if string(key) != tc.want {
+					t.Errorf("key = %q, want %q", key, tc.want)
+				}
+			}
+		})
+	}
+}
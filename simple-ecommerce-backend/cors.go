@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"learn_go/cors"
+)
+
+var (
+	corsPolicy     *cors.Policy
+	corsPolicyErr  error
+	corsPolicyOnce sync.Once
+)
+
+// getCORSPolicy builds the CORS policy from the configuration
+func getCORSPolicy() (*cors.Policy, error) {
+	corsPolicyOnce.Do(func() {
+		cfg, err := getConfig()
+		if err != nil {
+			corsPolicyErr = err
+			return
+		}
+		corsPolicy = cors.New(cfg.CORS)
+	})
+	return corsPolicy, corsPolicyErr
+}
+
+// handleCORS adds the CORS headers to a response and answers OPTIONS
+// requests for the Handler's routes. It returns true when the request has
+// been answered and the Handler should stop.
+func handleCORS(w http.ResponseWriter, r *http.Request, routes routeTable) bool {
+	policy, err := getCORSPolicy()
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Configuration unavailable"})
+		return true
+	}
+
+	_, methods := routes.match(r.URL.Path)
+	if methods == nil && r.Method == http.MethodOptions {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Not found"})
+		return true
+	}
+	return policy.Handle(w, r, methods)
+}
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// preflightCases are paths as the Handlers route them, with the methods each
+// one serves. Every pattern in every route table must be reached by at least
+// one case, so a route added to a Handler without its table entry, or the
+// other way round, shows up here.
+var preflightCases = []struct {
+	table   string
+	path    string
+	methods string // Empty when the Handler doesn't serve the path
+}{
+	{"admin", "/admin/reviews", "GET"},
+	{"admin", "/admin/reviews/r1/hide", "POST"},
+	{"admin", "/admin/reviews/r1/publish", "POST"},
+	{"admin", "/admin/products/import", "POST"},
+	{"admin", "/admin/products/export", "GET"},
+	{"admin", "/admin/products/archived", "GET"},
+	{"admin", "/admin/products/purge", "POST"},
+	{"admin", "/admin/products/p1/restore", "POST"},
+	{"admin", "/admin/products/p1/purge", "POST"},
+	{"admin", "/admin/orders", "GET"},
+	{"admin", "/admin/analytics/revenue", "GET"},
+	{"admin", "/admin/promotions", "GET"},
+	{"admin", "/admin/notifications", "GET"},
+	{"admin", "/admin/notifications/n1", "DELETE"},
+	{"admin", "/admin/reviews/r1/delete", ""},
+
+	{"cart", "/carts/u1", "GET, POST, PUT, DELETE"},
+	{"cart", "/cart/u1", "GET, POST, PUT, DELETE"},
+	{"cart", "/carts/u1/", "GET, POST, PUT, DELETE"},
+	{"cart", "/carts/u1/coupon", "POST, DELETE"},
+	{"cart", "/carts/u1/shipping", "GET"},
+	{"cart", "/carts", ""},
+
+	{"orders", "/orders/u1", "GET, POST"},
+	{"orders", "/orders/u1/o1", "GET"},
+	{"orders", "/orders/u1/o1/returns", "GET, POST"},
+	{"orders", "/orders/u1/o1/invoice", "GET"},
+	{"orders", "/orders/u1/o1/shipments", "GET"},
+
+	{"products", "/products", "GET, POST"},
+	{"products", "/products/", "GET, POST"},
+	{"products", "/products/low-stock", "GET"},
+	{"products", "/products/p1", "GET, PUT, PATCH, DELETE"},
+	{"products", "/products/p1/inventory", "GET, POST"},
+	{"products", "/products/p1/notify", "POST"},
+	{"products", "/products/p1/images", "GET, POST, PUT"},
+	{"products", "/products/p1/images/img1", "GET, PUT, DELETE"},
+	{"products", "/products/p1/images/img1/file", "GET, HEAD"},
+	{"products", "/products/p1/images/img1/thumbnail", "GET, HEAD"},
+	{"products", "/products/p1/prices", "GET, POST"},
+	{"products", "/products/p1/prices/pr1", "DELETE"},
+	{"products", "/products/p1/reviews", "GET, POST"},
+	{"products", "/products/p1/reviews/r1", "PUT, DELETE"},
+	{"products", "/products/p1/reviews/r1/flag", "POST"},
+	{"products", "/products/p1/bogus", ""},
+
+	{"promotions", "/promotions", "GET, POST"},
+
+	{"returns", "/returns", "GET"},
+	{"returns", "/returns/ret1", "GET"},
+	{"returns", "/returns/ret1/approve", "POST"},
+	{"returns", "/returns/ret1/reject", "POST"},
+	{"returns", "/returns/ret1/receive", "POST"},
+	{"returns", "/returns/ret1/refund", "POST"},
+
+	{"shipments", "/shipments", "GET, POST"},
+	{"shipments", "/shipments/s1", "GET"},
+	{"shipments", "/shipments/s1/deliver", "POST"},
+
+	{"users", "/users/login", "POST"},
+	{"users", "/users/register", "POST"},
+	{"users", "/users/u1", "GET"},
+	{"users", "/users/u1/addresses", "GET, POST"},
+	{"users", "/users/u1/addresses/a1", "GET, PUT, DELETE"},
+
+	{"wishlists", "/wishlists/shared/tok", "GET"},
+	{"wishlists", "/wishlists/u1", "GET, POST"},
+	{"wishlists", "/wishlists/u1/w1", "GET, PUT, DELETE"},
+	{"wishlists", "/wishlists/u1/w1/items", "POST"},
+	{"wishlists", "/wishlists/u1/w1/items/p1", "DELETE"},
+	{"wishlists", "/wishlists/u1/w1/items/p1/move", "POST"},
+	{"wishlists", "/wishlists/u1/w1/share", "POST, DELETE"},
+}
+
+// routeTables names each Handler's table
+var routeTables = map[string]routeTable{
+	"admin":      adminRoutes,
+	"cart":       cartRoutes,
+	"orders":     orderRoutes,
+	"products":   productRoutes,
+	"promotions": promotionRoutes,
+	"returns":    returnRoutes,
+	"shipments":  shipmentRoutes,
+	"users":      userRoutes,
+	"wishlists":  wishlistRoutes,
+}
+
+func TestPreflight(t *testing.T) {
+	for _, tc := range preflightCases {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodOptions, tc.path, nil)
+		req.Header.Set("Origin", "https://shop.example.com")
+		req.Header.Set("Access-Control-Request-Method", "GET")
+
+		if !handleCORS(rec, req, routeTables[tc.table]) {
+			t.Errorf("OPTIONS %s was passed on to the Handler", tc.path)
+			continue
+		}
+		if tc.methods == "" {
+			if rec.Code != http.StatusNotFound {
+				t.Errorf("OPTIONS %s = %d, want 404", tc.path, rec.Code)
+			}
+			continue
+		}
+		if rec.Code != http.StatusNoContent {
+			t.Errorf("OPTIONS %s = %d, want 204", tc.path, rec.Code)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Methods"); got != tc.methods {
+			t.Errorf("OPTIONS %s allows %q, want %q", tc.path, got, tc.methods)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+			t.Errorf("OPTIONS %s allows origin %q, want *", tc.path, got)
+		}
+	}
+}
+
+func TestPreflightCoversEveryRoute(t *testing.T) {
+	covered := map[string]bool{}
+	for _, tc := range preflightCases {
+		pattern, _ := routeTables[tc.table].match(tc.path)
+		covered[tc.table+" "+pattern] = true
+	}
+	for name, routes := range routeTables {
+		for pattern := range routes {
+			if !covered[name+" "+pattern] {
+				t.Errorf("%s route %s has no preflight case", name, pattern)
+			}
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"learn_go/health"
+)
+
+// healthChecker decides readiness. The store keeps its data in memory, so
+// there is no database to reach and no migrations to check; what can fail
+// is the blob store and the configuration files loaded on first use.
+var healthChecker = newHealthChecker()
+
+func newHealthChecker() *health.Checker {
+	checker := health.NewChecker()
+	checker.AddCheck("blobStore", checkBlobStore)
+	checker.AddCheck("config", checkConfig)
+	return checker
+}
+
+// blobStoreCheckInterval is how long a blob store check result is reused.
+// Probes arrive every few seconds; there's no need to touch the disk for
+// each one.
+const blobStoreCheckInterval = 30 * time.Second
+
+// blobStoreCheck is the last blob store check result
+var blobStoreCheck struct {
+	sync.Mutex
+	at  time.Time
+	err error
+}
+
+// checkBlobStore writes and removes a small file to prove uploads will
+// work, at most once per blobStoreCheckInterval
+func checkBlobStore(ctx context.Context) error {
+	blobStoreCheck.Lock()
+	defer blobStoreCheck.Unlock()
+	if !blobStoreCheck.at.IsZero() && time.Since(blobStoreCheck.at) < blobStoreCheckInterval {
+		return blobStoreCheck.err
+	}
+
+	blobStoreCheck.err = probeBlobStore()
+	blobStoreCheck.at = time.Now()
+	return blobStoreCheck.err
+}
+
+// probeBlobStore puts and deletes a file in the blob store
+func probeBlobStore() error {
+	store, err := getBlobStore()
+	if err != nil {
+		return err
+	}
+	const key = "health/readyz"
+	err = store.Put(key, strings.NewReader("ok"))
+	if err != nil {
+		return err
+	}
+	return store.Delete(key)
+}
+
+// checkConfig loads the settings and the tax, shipping and seller files
+// checkout needs
+func checkConfig(ctx context.Context) error {
+	_, err := getConfig()
+	if err != nil {
+		return err
+	}
+	_, err = getTaxCalculator()
+	if err != nil {
+		return err
+	}
+	_, err = getShippingMethods()
+	if err != nil {
+		return err
+	}
+	_, err = getSeller()
+	return err
+}
+
+// inFlight counts requests being served, so shutdown can wait for them
+var inFlight atomic.Int64
+
+// BeginShutdown makes /readyz fail from now on, so the orchestrator stops
+// routing traffic here. This package is a library called by whatever hosts
+// it, so it leaves signals to the host: on SIGTERM the host calls
+// BeginShutdown, waits config.ShutdownConfig.Delay, stops accepting
+// connections and then calls Drain.
+func BeginShutdown() {
+	if !healthChecker.ShuttingDown() {
+		healthChecker.SetShuttingDown()
+		logger.Info("shutting down")
+	}
+}
+
+// Drain waits for requests in flight to finish. It returns ctx's error if
+// ctx is done first, such as when config.ShutdownConfig.Timeout passes.
+func Drain(ctx context.Context) error {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for inFlight.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			logger.Warn("requests still in flight", "requests", inFlight.Load())
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// Handler serves the orchestrator's probes: /healthz, /readyz and /version.
+// Probes aren't logged, measured or traced, since they arrive every few
+// seconds and would drown out real traffic.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	pathParts := strings.Split(r.URL.Path, "/")
+	probe := ""
+	if len(pathParts) > 1 {
+		probe = pathParts[1]
+	}
+	switch probe {
+	case "healthz":
+		healthChecker.LivenessHandler().ServeHTTP(w, r)
+	case "readyz":
+		healthChecker.ReadinessHandler().ServeHTTP(w, r)
+	case "version":
+		health.VersionHandler().ServeHTTP(w, r)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Not found"})
+	}
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// countingBlobStore counts the files written to a blob store
+type countingBlobStore struct {
+	BlobStore
+	puts int
+}
+
+func (s *countingBlobStore) Put(key string, r io.Reader) error {
+	s.puts++
+	return s.BlobStore.Put(key, r)
+}
+
+// forgetBlobStoreCheck makes the next readiness probe check the blob store
+func forgetBlobStoreCheck(t *testing.T) {
+	blobStoreCheck.at = time.Time{}
+	t.Cleanup(func() { blobStoreCheck.at = time.Time{} })
+}
+
+func TestBlobStoreCheckIsCached(t *testing.T) {
+	useTempBlobStore(t)
+	forgetBlobStoreCheck(t)
+	store := &countingBlobStore{BlobStore: blobStore}
+	blobStore = store
+
+	for i := 0; i < 3; i++ {
+		if err := checkBlobStore(t.Context()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if store.puts != 1 {
+		t.Errorf("%d writes for three probes, want 1", store.puts)
+	}
+
+	// The check runs again once the result is stale
+	blobStoreCheck.at = time.Now().Add(-blobStoreCheckInterval)
+	checkBlobStore(t.Context())
+	if store.puts != 2 {
+		t.Errorf("%d writes after the interval, want 2", store.puts)
+	}
+}
+
+func TestBeginShutdownFailsReadiness(t *testing.T) {
+	useTempBlobStore(t)
+	forgetBlobStoreCheck(t)
+	keepValue(t, &healthChecker)
+	healthChecker = newHealthChecker()
+
+	probe := func(handler string) int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/"+handler, nil)
+		if handler == "readyz" {
+			healthChecker.ReadinessHandler().ServeHTTP(w, r)
+		} else {
+			healthChecker.LivenessHandler().ServeHTTP(w, r)
+		}
+		return w.Code
+	}
+
+	if code := probe("readyz"); code != 200 {
+		t.Fatalf("readyz before shutdown: status %d, want 200", code)
+	}
+
+	BeginShutdown()
+	BeginShutdown() // Repeated signals are harmless
+	if code := probe("readyz"); code != 503 {
+		t.Errorf("readyz after BeginShutdown: status %d, want 503", code)
+	}
+	// Still alive, so it isn't restarted while draining
+	if code := probe("healthz"); code != 200 {
+		t.Errorf("healthz after BeginShutdown: status %d, want 200", code)
+	}
+}
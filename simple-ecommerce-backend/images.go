@@ -0,0 +1,558 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Upload limits and thumbnail size
+const (
+	maxImageBytes  = 5 << 20    // Largest accepted upload
+	maxImagePixels = 40_000_000 // Guards against decompression bombs
+	thumbnailSize  = 256        // Longest edge of generated thumbnails
+
+	// Image IDs are random and ETags are content hashes, so a URL never
+	// serves different bytes and browsers and CDNs may cache it forever
+	imageCacheControl = "public, max-age=31536000, immutable"
+)
+
+// allowedImageTypes maps accepted content types to file extensions. Only
+// formats the standard library can decode are accepted, since every upload
+// gets a thumbnail.
+var allowedImageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// ProductImage is an uploaded product photo
+type ProductImage struct {
+	ID           string    `json:"id"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnailUrl"`
+	AltText      string    `json:"altText"`
+	Position     int       `json:"position"` // 0 is the primary image
+	ContentType  string    `json:"contentType"`
+	Size         int64     `json:"size"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	CreatedAt    time.Time `json:"createdAt"`
+
+	// Blob store keys of the original and the thumbnail, and the SHA-256 of
+	// each, which serves as its ETag
+	StorageKey        string `json:"-"`
+	ThumbnailKey      string `json:"-"`
+	ThumbnailType     string `json:"-"`
+	Checksum          string `json:"-"`
+	ThumbnailChecksum string `json:"-"`
+}
+
+// ImageUpdateRequest represents a request to change an image's alt text or position
+type ImageUpdateRequest struct {
+	AltText  *string `json:"altText"`
+	Position *int    `json:"position"`
+}
+
+// ImageOrderRequest represents a request to reorder all of a product's images
+type ImageOrderRequest struct {
+	ImageIDs []string `json:"imageIds"`
+}
+
+// newImageID generates a random image ID. Blobs outlive the process, so a
+// counter would start over after a restart and hand out URLs that browsers
+// have already cached for another image.
+func newImageID() (string, error) {
+	b := make([]byte, 8)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return "img" + hex.EncodeToString(b), nil
+}
+
+// checksum returns the hex SHA-256 of data
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// syncImages renumbers positions after a change and points ImageURL at the
+// primary image
+func syncImages(product *Product) {
+	for i := range product.Images {
+		product.Images[i].Position = i
+	}
+	if len(product.Images) > 0 {
+		product.ImageURL = product.Images[0].URL
+	}
+}
+
+// moveImage moves the image at index from to index to, shifting the others
+func moveImage(images []ProductImage, from, to int) {
+	if to < 0 {
+		to = 0
+	}
+	if to >= len(images) {
+		to = len(images) - 1
+	}
+	moved := images[from]
+	if from < to {
+		copy(images[from:to], images[from+1:to+1])
+	} else {
+		copy(images[to+1:from+1], images[to:from])
+	}
+	images[to] = moved
+}
+
+// makeThumbnail scales an image to fit within size x size by averaging the
+// source pixels under each thumbnail pixel. Images that already fit are
+// returned unchanged.
+func makeThumbnail(src image.Image, size int) image.Image {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= size && height <= size {
+		return src
+	}
+
+	thumbWidth, thumbHeight := size, size
+	if width > height {
+		thumbHeight = max(1, height*size/width)
+	} else {
+		thumbWidth = max(1, width*size/height)
+	}
+
+	thumb := image.NewRGBA(image.Rect(0, 0, thumbWidth, thumbHeight))
+	for ty := 0; ty < thumbHeight; ty++ {
+		y0 := bounds.Min.Y + ty*height/thumbHeight
+		y1 := max(y0+1, bounds.Min.Y+(ty+1)*height/thumbHeight)
+		for tx := 0; tx < thumbWidth; tx++ {
+			x0 := bounds.Min.X + tx*width/thumbWidth
+			x1 := max(x0+1, bounds.Min.X+(tx+1)*width/thumbWidth)
+
+			// Sample at most 8x8 pixels per box to keep large uploads fast
+			stepY := max(1, (y1-y0)/8)
+			stepX := max(1, (x1-x0)/8)
+			var r, g, b, a, n uint32
+			for y := y0; y < y1; y += stepY {
+				for x := x0; x < x1; x += stepX {
+					pr, pg, pb, pa := src.At(x, y).RGBA()
+					r += pr
+					g += pg
+					b += pb
+					a += pa
+					n++
+				}
+			}
+
+			offset := thumb.PixOffset(tx, ty)
+			thumb.Pix[offset+0] = uint8(r / n >> 8)
+			thumb.Pix[offset+1] = uint8(g / n >> 8)
+			thumb.Pix[offset+2] = uint8(b / n >> 8)
+			thumb.Pix[offset+3] = uint8(a / n >> 8)
+		}
+	}
+	return thumb
+}
+
+// encodeThumbnail writes a thumbnail as JPEG for photos and PNG otherwise,
+// so transparency survives
+func encodeThumbnail(thumb image.Image, sourceType string) ([]byte, string, error) {
+	var buf bytes.Buffer
+	if sourceType == "image/jpeg" {
+		err := jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 85})
+		return buf.Bytes(), "image/jpeg", err
+	}
+	err := png.Encode(&buf, thumb)
+	return buf.Bytes(), "image/png", err
+}
+
+// decodeImage checks an upload's real content type and decodes it
+func decodeImage(data []byte) (image.Image, string, error) {
+	contentType := mimetype.Detect(data).String()
+	if _, ok := allowedImageTypes[contentType]; !ok {
+		return nil, contentType, errors.New("Unsupported image type " + contentType)
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, contentType, errors.New("Image could not be decoded")
+	}
+	if config.Width*config.Height > maxImagePixels {
+		return nil, contentType, errors.New("Image dimensions are too large")
+	}
+
+	var img image.Image
+	switch contentType {
+	case "image/jpeg":
+		img, err = jpeg.Decode(bytes.NewReader(data))
+	case "image/png":
+		img, err = png.Decode(bytes.NewReader(data))
+	case "image/gif":
+		img, err = gif.Decode(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, contentType, errors.New("Image could not be decoded")
+	}
+	return img, contentType, nil
+}
+
+// handleProductImages processes image requests for a product
+func handleProductImages(w http.ResponseWriter, r *http.Request, productID string, pathParts []string) {
+	r, span := startSpan(r, "handleProductImages", attribute.String("product.id", productID))
+	defer span.End()
+
+	// Find product by ID
+	product := findProduct(r.Context(), productID)
+
+	// Product not found
+	if product == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Product not found"})
+		return
+	}
+
+	// Handle specific image
+	if len(pathParts) > 4 && pathParts[4] != "" {
+		handleSingleImage(w, r, product, pathParts)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		json.NewEncoder(w).Encode(product.Images)
+	case "POST":
+		uploadProductImage(w, r, product)
+	case "PUT":
+		reorderProductImages(w, r, product)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// uploadProductImage stores a multipart upload in the "image" field, with
+// optional "altText" and "position" fields, and generates its thumbnail
+func uploadProductImage(w http.ResponseWriter, r *http.Request, product *Product) {
+	// Leave room for the other form fields and multipart framing
+	r.Body = http.MaxBytesReader(w, r.Body, maxImageBytes+64<<10)
+	err := r.ParseMultipartForm(maxImageBytes)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Image must be at most " + strconv.Itoa(maxImageBytes>>20) + " MB"})
+			return
+		}
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, _, err := r.FormFile("image")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Image file required"})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxImageBytes+1))
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(data) > maxImageBytes {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Image must be at most " + strconv.Itoa(maxImageBytes>>20) + " MB"})
+		return
+	}
+
+	// Trust the bytes, not the client's Content-Type
+	img, contentType, err := decodeImage(data)
+	if err != nil {
+		status := http.StatusBadRequest
+		if _, ok := allowedImageTypes[contentType]; !ok {
+			status = http.StatusUnsupportedMediaType
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	thumbData, thumbType, err := encodeThumbnail(makeThumbnail(img, thumbnailSize), contentType)
+	if err != nil {
+		requestLogger(r).Error("failed to create thumbnail", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to create thumbnail"})
+		return
+	}
+
+	store, err := getBlobStore()
+	if err != nil {
+		requestLogger(r).Error("image storage unavailable", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Image storage unavailable"})
+		return
+	}
+
+	imageID, err := newImageID()
+	if err != nil {
+		requestLogger(r).Error("failed to generate image ID", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to store image"})
+		return
+	}
+	base := "/products/" + product.ID + "/images/" + imageID
+	stored := ProductImage{
+		ID:                imageID,
+		URL:               base + "/file",
+		ThumbnailURL:      base + "/thumbnail",
+		AltText:           strings.TrimSpace(r.FormValue("altText")),
+		ContentType:       contentType,
+		Size:              int64(len(data)),
+		Width:             img.Bounds().Dx(),
+		Height:            img.Bounds().Dy(),
+		CreatedAt:         time.Now(),
+		StorageKey:        "products/" + product.ID + "/" + imageID + allowedImageTypes[contentType],
+		ThumbnailKey:      "products/" + product.ID + "/" + imageID + "-thumb" + allowedImageTypes[thumbType],
+		ThumbnailType:     thumbType,
+		Checksum:          checksum(data),
+		ThumbnailChecksum: checksum(thumbData),
+	}
+
+	err = store.Put(stored.StorageKey, bytes.NewReader(data))
+	if err == nil {
+		err = store.Put(stored.ThumbnailKey, bytes.NewReader(thumbData))
+	}
+	if err != nil {
+		store.Delete(stored.StorageKey)
+		requestLogger(r).Error("failed to store image", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to store image"})
+		return
+	}
+
+	// New images go last unless a position is given
+	product.Images = append(product.Images, stored)
+	if position := r.FormValue("position"); position != "" {
+		to, err := strconv.Atoi(position)
+		if err == nil {
+			moveImage(product.Images, len(product.Images)-1, to)
+		}
+	}
+	syncImages(product)
+	product.Version++
+
+	for _, saved := range product.Images {
+		if saved.ID == imageID {
+			stored = saved
+			break
+		}
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(stored)
+}
+
+// reorderProductImages sets the order of all of a product's images at once
+func reorderProductImages(w http.ResponseWriter, r *http.Request, product *Product) {
+	var req ImageOrderRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.ImageIDs) != len(product.Images) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Every image must be listed exactly once"})
+		return
+	}
+
+	reordered := make([]ProductImage, 0, len(product.Images))
+	seen := map[string]bool{}
+	for _, id := range req.ImageIDs {
+		found := false
+		for _, img := range product.Images {
+			if img.ID == id && !seen[id] {
+				reordered = append(reordered, img)
+				seen[id] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Every image must be listed exactly once"})
+			return
+		}
+	}
+
+	product.Images = reordered
+	syncImages(product)
+	product.Version++
+
+	json.NewEncoder(w).Encode(product.Images)
+}
+
+// handleSingleImage serves, updates or deletes one image
+func handleSingleImage(w http.ResponseWriter, r *http.Request, product *Product, pathParts []string) {
+	imageID := pathParts[4]
+
+	// Find image by ID
+	index := -1
+	for i := range product.Images {
+		if product.Images[i].ID == imageID {
+			index = i
+			break
+		}
+	}
+
+	// Image not found
+	if index < 0 {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Image not found"})
+		return
+	}
+	img := &product.Images[index]
+
+	// Handle file and thumbnail downloads
+	if len(pathParts) > 5 && (pathParts[5] == "file" || pathParts[5] == "thumbnail") {
+		if r.Method != "GET" && r.Method != "HEAD" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if pathParts[5] == "thumbnail" {
+			serveBlob(w, r, img.ThumbnailKey, img.ThumbnailType, img.ThumbnailChecksum)
+		} else {
+			serveBlob(w, r, img.StorageKey, img.ContentType, img.Checksum)
+		}
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		json.NewEncoder(w).Encode(img)
+	case "PUT":
+		var req ImageUpdateRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.AltText != nil {
+			img.AltText = strings.TrimSpace(*req.AltText)
+		}
+		if req.Position != nil {
+			moveImage(product.Images, index, *req.Position)
+		}
+		syncImages(product)
+		product.Version++
+
+		for _, updated := range product.Images {
+			if updated.ID == imageID {
+				json.NewEncoder(w).Encode(updated)
+				break
+			}
+		}
+	case "DELETE":
+		// Missing blobs don't matter; the image is going anyway
+		store, err := getBlobStore()
+		if err == nil {
+			store.Delete(img.StorageKey)
+			store.Delete(img.ThumbnailKey)
+		}
+
+		product.Images = append(product.Images[:index], product.Images[index+1:]...)
+		if len(product.Images) == 0 {
+			product.ImageURL = ""
+		}
+		syncImages(product)
+		product.Version++
+
+		json.NewEncoder(w).Encode(map[string]string{"message": "Image deleted"})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// serveBlob streams a stored file with long-lived caching headers
+func serveBlob(w http.ResponseWriter, r *http.Request, key, contentType, etag string) {
+	etag = `"` + etag + `"`
+	w.Header().Set("Cache-Control", imageCacheControl)
+	w.Header().Set("ETag", etag)
+
+	// Content never changes for a given URL, so a matching ETag is enough
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == etag || candidate == "*" {
+				w.Header().Del("Content-Type")
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+	}
+
+	store, err := getBlobStore()
+	if err != nil {
+		w.Header().Del("Cache-Control")
+		requestLogger(r).Error("image storage unavailable", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Image storage unavailable"})
+		return
+	}
+
+	blob, info, err := store.Get(key)
+	if err != nil {
+		w.Header().Del("Cache-Control")
+		w.Header().Del("ETag")
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrBlobNotFound) {
+			status = http.StatusNotFound
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Image file unavailable"})
+		return
+	}
+	defer blob.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	w.Header().Set("Last-Modified", info.LastModified.UTC().Format(http.TimeFormat))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if r.Method == "HEAD" {
+		return
+	}
+	io.Copy(w, blob)
+}
+
+// deleteProductImages removes a product's stored files
+func deleteProductImages(product Product) {
+	if len(product.Images) == 0 {
+		return
+	}
+	store, err := getBlobStore()
+	if err != nil {
+		return
+	}
+	for _, img := range product.Images {
+		store.Delete(img.StorageKey)
+		store.Delete(img.ThumbnailKey)
+	}
+}
@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testPNG encodes a solid width x height PNG
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// uploadImage posts data as a product image under the given file name and
+// content type
+func uploadImage(t *testing.T, product *Product, filename, contentType string, data []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	header := map[string][]string{
+		"Content-Disposition": {`form-data; name="image"; filename="` + filename + `"`},
+		"Content-Type":        {contentType},
+	}
+	part, _ := form.CreatePart(header)
+	part.Write(data)
+	form.WriteField("altText", " Front ")
+	form.Close()
+
+	r := httptest.NewRequest("POST", "/products/"+product.ID+"/images", &body)
+	r.Header.Set("Content-Type", form.FormDataContentType())
+	w := httptest.NewRecorder()
+	uploadProductImage(w, r, product)
+	return w
+}
+
+// useTempBlobStore stores uploads in a directory removed after the test
+func useTempBlobStore(t *testing.T) {
+	keepValue(t, &blobStore)
+	keepValue(t, &blobStoreErr)
+	blobStore, blobStoreErr = LocalBlobStore{Dir: t.TempDir()}, nil
+	blobStoreOnce.Do(func() {})
+}
+
+func TestDecodeImageSniffsContent(t *testing.T) {
+	// A PNG whose header claims 10000 x 10000 pixels
+	bomb := testPNG(t, 1, 1)
+	binary.BigEndian.PutUint32(bomb[16:], 10000)
+	binary.BigEndian.PutUint32(bomb[20:], 10000)
+	binary.BigEndian.PutUint32(bomb[29:], crc32.ChecksumIEEE(bomb[12:29]))
+
+	tests := []struct {
+		name        string
+		data        []byte
+		contentType string
+		ok          bool
+	}{
+		{"png", testPNG(t, 4, 3), "image/png", true},
+		{"html", []byte("<html><script>alert(1)</script></html>"), "text/html; charset=utf-8", false},
+		{"decompression bomb", bomb, "image/png", false},
+		{"truncated png", testPNG(t, 4, 3)[:40], "image/png", false},
+	}
+	for _, tc := range tests {
+		_, contentType, err := decodeImage(tc.data)
+		if contentType != tc.contentType || (err == nil) != tc.ok {
+			t.Errorf("%s: detected %q, error %v; want %q, ok = %v", tc.name, contentType, err, tc.contentType, tc.ok)
+		}
+	}
+}
+
+func TestMakeThumbnail(t *testing.T) {
+	small := image.NewRGBA(image.Rect(0, 0, 100, 50))
+	if makeThumbnail(small, thumbnailSize) != image.Image(small) {
+		t.Error("an image that already fits was resized")
+	}
+
+	wide := image.NewRGBA(image.Rect(0, 0, 1000, 500))
+	for x := 0; x < 500; x++ {
+		for y := 0; y < 500; y++ {
+			wide.Set(x, y, color.RGBA{R: 0xff, A: 0xff})
+		}
+	}
+	thumb := makeThumbnail(wide, thumbnailSize)
+	if bounds := thumb.Bounds(); bounds.Dx() != 256 || bounds.Dy() != 128 {
+		t.Fatalf("thumbnail is %v, want 256x128", bounds)
+	}
+	if r, _, _, a := thumb.At(10, 10).RGBA(); r>>8 != 0xff || a>>8 != 0xff {
+		t.Errorf("left of thumbnail is %v, want opaque red", thumb.At(10, 10))
+	}
+	if _, _, _, a := thumb.At(250, 10).RGBA(); a != 0 {
+		t.Errorf("right of thumbnail is %v, want transparent", thumb.At(250, 10))
+	}
+}
+
+func TestUploadProductImage(t *testing.T) {
+	useTempBlobStore(t)
+	product := &Product{ID: "p9"}
+
+	// The client's file name and content type are ignored
+	w := uploadImage(t, product, "photo.jpg", "image/jpeg", testPNG(t, 600, 300))
+	if w.Code != 201 {
+		t.Fatalf("upload: status %d: %s", w.Code, w.Body)
+	}
+	var stored ProductImage
+	json.NewDecoder(w.Body).Decode(&stored)
+	if stored.ContentType != "image/png" || stored.Width != 600 || stored.AltText != "Front" {
+		t.Errorf("stored %+v, want a 600px image/png with trimmed alt text", stored)
+	}
+	if product.ImageURL != stored.URL || !strings.HasSuffix(product.Images[0].StorageKey, ".png") {
+		t.Errorf("product image %q, key %q", product.ImageURL, product.Images[0].StorageKey)
+	}
+
+	// The thumbnail is served with its own ETag
+	path := stored.ThumbnailURL
+	w = httptest.NewRecorder()
+	handleSingleImage(w, httptest.NewRequest("GET", path, nil), product, strings.Split(path, "/"))
+	thumb, _, err := image.DecodeConfig(w.Body)
+	if w.Code != 200 || err != nil || thumb.Width != thumbnailSize || thumb.Height != thumbnailSize/2 {
+		t.Errorf("thumbnail: status %d, %dx%d, %v", w.Code, thumb.Width, thumb.Height, err)
+	}
+	r := httptest.NewRequest("GET", path, nil)
+	r.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	handleSingleImage(w, r, product, strings.Split(path, "/"))
+	if w.Code != 304 {
+		t.Errorf("revalidated thumbnail: status %d, want 304", w.Code)
+	}
+
+	// Anything else is refused before it is stored
+	refused := []struct {
+		name   string
+		data   []byte
+		status int
+	}{
+		{"not an image", []byte("GIF89a is what I claim to be"), 400},
+		{"html", []byte("<!DOCTYPE html><p>hi</p>"), 415},
+		{"too large", append(testPNG(t, 1, 1), make([]byte, maxImageBytes)...), 413},
+	}
+	for _, tc := range refused {
+		if w := uploadImage(t, product, "x.png", "image/png", tc.data); w.Code != tc.status {
+			t.Errorf("%s: status %d, want %d", tc.name, w.Code, tc.status)
+		}
+	}
+	if len(product.Images) != 1 {
+		t.Errorf("product has %d images, want only the valid upload", len(product.Images))
+	}
+}
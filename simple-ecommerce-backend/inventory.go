@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Inventory entry types
+const (
+	InventorySale         = "sale"
+	InventoryRestock      = "restock"
+	InventoryAdjustment   = "adjustment"
+	InventoryReturn       = "return"
+	InventoryCancellation = "cancellation"
+)
+
+// InventoryEntry represents a single stock movement in the ledger
+type InventoryEntry struct {
+	ID        string    `json:"id"`
+	ProductID string    `json:"productId"`
+	Type      string    `json:"type"`
+	Quantity  int       `json:"quantity"` // Positive adds stock, negative removes it
+	Reason    string    `json:"reason,omitempty"`
+	Reference string    `json:"reference,omitempty"` // e.g. the order ID for sales
+	Balance   int       `json:"balance"`             // Stock level after this entry
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// InventoryRequest represents a request to post a stock movement
+type InventoryRequest struct {
+	Type      string `json:"type"`
+	Quantity  int    `json:"quantity"`
+	Reason    string `json:"reason"`
+	Reference string `json:"reference"`
+}
+
+// InventoryHistory is what we return for a product's ledger
+type InventoryHistory struct {
+	ProductID string           `json:"productId"`
+	Stock     int              `json:"stock"`
+	Entries   []InventoryEntry `json:"entries"`
+}
+
+// In-memory inventory ledger for demo purposes
+// Entries are only ever appended, never updated or removed
+var inventoryLedger = []InventoryEntry{
+	{ID: "inv1", ProductID: "p1", Type: InventoryRestock, Quantity: 52, Reason: "Opening stock", Balance: 52, CreatedAt: time.Now().Add(-48 * time.Hour)},
+	{ID: "inv2", ProductID: "p2", Type: InventoryRestock, Quantity: 101, Reason: "Opening stock", Balance: 101, CreatedAt: time.Now().Add(-11 * 24 * time.Hour)},
+	{ID: "inv3", ProductID: "p3", Type: InventoryRestock, Quantity: 30, Reason: "Opening stock", Balance: 30, CreatedAt: time.Now().Add(-48 * time.Hour)},
+	{ID: "inv4", ProductID: "p1", Type: InventorySale, Quantity: -2, Reference: "o1", Balance: 50, CreatedAt: time.Now().Add(-24 * time.Hour)},
+	{ID: "inv5", ProductID: "p2", Type: InventorySale, Quantity: -1, Reference: "o2", Balance: 100, CreatedAt: time.Now().Add(-10 * 24 * time.Hour)},
+}
+
+// stockFor derives the current stock of a product from the ledger
+func stockFor(productID string) int {
+	stock := 0
+	for _, entry := range inventoryLedger {
+		if entry.ProductID == productID {
+			stock += entry.Quantity
+		}
+	}
+	return stock
+}
+
+// recordInventory appends an entry to the ledger and refreshes the
+// product's Stock field, which is kept only as a cache of the ledger
+func recordInventory(ctx context.Context, productID, entryType string, quantity int, reason, reference string) InventoryEntry {
+	_, span := startStoreSpan(ctx, "recordInventory",
+		attribute.String("product.id", productID),
+		attribute.String("inventory.type", entryType),
+		attribute.Int("inventory.quantity", quantity),
+	)
+	defer span.End()
+
+	entry := InventoryEntry{
+		ID:        "inv" + strconv.Itoa(len(inventoryLedger)+1),
+		ProductID: productID,
+		Type:      entryType,
+		Quantity:  quantity,
+		Reason:    reason,
+		Reference: reference,
+		Balance:   stockFor(productID) + quantity,
+		CreatedAt: time.Now(),
+	}
+	inventoryLedger = append(inventoryLedger, entry)
+
+	// Update cached stock and fire any stock alerts
+	product := findProduct(ctx, productID)
+	if product != nil {
+		product.Stock = entry.Balance
+		checkStockAlerts(*product, entry.Balance-quantity, entry.Balance, reference)
+	}
+
+	return entry
+}
+
+// handleInventory handles requests for a product's inventory ledger
+func handleInventory(w http.ResponseWriter, r *http.Request, productID string) {
+	r, span := startSpan(r, "handleInventory", attribute.String("product.id", productID))
+	defer span.End()
+
+	// Find product by ID
+	product := findProduct(r.Context(), productID)
+
+	// Product not found
+	if product == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Product not found"})
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		getInventoryHistory(w, r, productID)
+	case "POST":
+		postInventoryEntry(w, r, productID)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// getInventoryHistory returns the ledger entries for a product
+func getInventoryHistory(w http.ResponseWriter, r *http.Request, productID string) {
+	// Optional filter by entry type
+	entryType := r.URL.Query().Get("type")
+
+	entries := []InventoryEntry{}
+	for _, entry := range inventoryLedger {
+		if entry.ProductID != productID {
+			continue
+		}
+		if entryType != "" && entry.Type != entryType {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	json.NewEncoder(w).Encode(InventoryHistory{
+		ProductID: productID,
+		Stock:     stockFor(productID),
+		Entries:   entries,
+	})
+}
+
+// postInventoryEntry records a restock, manual adjustment, return or cancellation
+func postInventoryEntry(w http.ResponseWriter, r *http.Request, productID string) {
+	var req InventoryRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate entry type and quantity
+	switch req.Type {
+	case InventoryRestock, InventoryReturn, InventoryCancellation:
+		if req.Quantity <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Quantity must be positive"})
+			return
+		}
+	case InventoryAdjustment:
+		if req.Quantity == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Quantity must not be zero"})
+			return
+		}
+		if req.Reason == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Reason required for adjustments"})
+			return
+		}
+	case InventorySale:
+		// Sales are only recorded by checkout
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Sales are recorded by checkout"})
+		return
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Unknown inventory type"})
+		return
+	}
+
+	// Stock can never go negative
+	if stockFor(productID)+req.Quantity < 0 {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Adjustment would make stock negative"})
+		return
+	}
+
+	entry := recordInventory(r.Context(), productID, req.Type, req.Quantity, req.Reason, req.Reference)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(entry)
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProductEditsLeaveStockToTheLedger(t *testing.T) {
+	keepStore(t)
+
+	// Read p1, then sell two before the edits below arrive
+	etag := productETag(*findProduct(context.Background(), "p1"))
+	recordInventory(context.Background(), "p1", InventorySale, -2, "", "o9")
+
+	edits := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+	}{
+		{"PUT without stock", "PUT", "", `{"name":"Y","price":10}`},
+		{"PUT with stock read before the sale", "PUT", "", `{"name":"Y","price":10,"stock":50}`},
+		{"PATCH setting stock", "PATCH", "application/merge-patch+json", `{"stock":0}`},
+	}
+	for _, edit := range edits {
+		r := httptest.NewRequest(edit.method, "/products/p1", strings.NewReader(edit.body))
+		if edit.contentType != "" {
+			r.Header.Set("Content-Type", edit.contentType)
+		}
+		w := httptest.NewRecorder()
+		handleSingleProduct(w, r, "p1")
+
+		if w.Code != 200 {
+			t.Fatalf("%s: status %d: %s", edit.name, w.Code, w.Body)
+		}
+		if stock := findProduct(context.Background(), "p1").Stock; stock != 48 || stockFor("p1") != 48 {
+			t.Errorf("%s: stock = %d, ledger = %d; want 48", edit.name, stock, stockFor("p1"))
+		}
+	}
+
+	// A write based on the read from before the edits is refused
+	r := httptest.NewRequest("PUT", "/products/p1", strings.NewReader(`{"name":"Z","price":10}`))
+	r.Header.Set("If-Match", etag)
+	w := httptest.NewRecorder()
+	handleSingleProduct(w, r, "p1")
+	if w.Code != 412 {
+		t.Errorf("stale If-Match: status %d, want 412", w.Code)
+	}
+
+	// The inventory endpoint is the way to change stock
+	r = httptest.NewRequest("POST", "/products/p1/inventory", strings.NewReader(`{"type":"restock","quantity":5}`))
+	w = httptest.NewRecorder()
+	handleInventory(w, r, "p1")
+	if w.Code != 201 || findProduct(context.Background(), "p1").Stock != 53 {
+		t.Errorf("restock: status %d, stock %d; want 201, 53", w.Code, findProduct(context.Background(), "p1").Stock)
+	}
+}
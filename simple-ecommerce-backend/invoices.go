@@ -0,0 +1,355 @@
+package handler
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// SellerDetails is the business information printed on invoices
+type SellerDetails struct {
+	Name          string  `json:"name"`
+	Address       Address `json:"address"`
+	Email         string  `json:"email"`
+	Phone         string  `json:"phone"`
+	TaxID         string  `json:"taxId"`
+	InvoicePrefix string  `json:"invoicePrefix"`
+	Currency      string  `json:"currency"`
+}
+
+// Invoice is an issued invoice. Invoices are kept even if their order is
+// later removed, so the numbering has no gaps or reuse.
+type Invoice struct {
+	Number       string         `json:"number"`
+	OrderID      string         `json:"orderId"`
+	UserID       string         `json:"userId"`
+	IssuedAt     time.Time      `json:"issuedAt"`
+	Seller       SellerDetails  `json:"seller"`
+	BillTo       Address        `json:"billTo"`
+	ShipTo       Address        `json:"shipTo"`
+	Contact      ContactDetails `json:"contact"`
+	Lines        []OrderItem    `json:"lines"`
+	Subtotal     float64        `json:"subtotal"`
+	Discounts    []DiscountLine `json:"discounts,omitempty"`
+	ShippingCost float64        `json:"shippingCost"`
+	TaxLines     []TaxLine      `json:"taxLines,omitempty"`
+	TaxTotal     float64        `json:"taxTotal"`
+	Total        float64        `json:"total"`
+}
+
+// defaultSeller is used when SELLER_CONFIG_FILE is not set
+//
+//go:embed seller.json
+var defaultSeller []byte
+
+var (
+	seller     SellerDetails
+	sellerErr  error
+	sellerOnce sync.Once
+)
+
+// In-memory invoice database for demo purposes
+var invoices = []Invoice{}
+
+// lastInvoiceNumber only ever goes up, so numbers are never reused
+var lastInvoiceNumber int
+
+// getSeller returns the seller details, loading them on first use
+func getSeller() (SellerDetails, error) {
+	sellerOnce.Do(func() {
+		data := defaultSeller
+		if path := os.Getenv("SELLER_CONFIG_FILE"); path != "" {
+			data, sellerErr = os.ReadFile(path)
+			if sellerErr != nil {
+				return
+			}
+		}
+		sellerErr = json.Unmarshal(data, &seller)
+	})
+	return seller, sellerErr
+}
+
+// issueInvoice returns the invoice for an order, issuing the next number
+// the first time an order is invoiced
+func issueInvoice(order Order) (Invoice, error) {
+	for _, invoice := range invoices {
+		if invoice.OrderID == order.ID {
+			return invoice, nil
+		}
+	}
+
+	details, err := getSeller()
+	if err != nil {
+		return Invoice{}, err
+	}
+
+	lastInvoiceNumber++
+	invoice := Invoice{
+		Number:       fmt.Sprintf("%s%06d", details.InvoicePrefix, lastInvoiceNumber),
+		OrderID:      order.ID,
+		UserID:       order.UserID,
+		IssuedAt:     time.Now(),
+		Seller:       details,
+		BillTo:       order.BillingAddr,
+		ShipTo:       order.ShippingAddr,
+		Contact:      order.Contact,
+		Lines:        order.Items,
+		Subtotal:     order.Subtotal,
+		Discounts:    order.Discounts,
+		ShippingCost: order.ShippingCost,
+		TaxLines:     order.TaxLines,
+		TaxTotal:     order.TaxTotal,
+		Total:        order.TotalAmount,
+	}
+	invoices = append(invoices, invoice)
+
+	return invoice, nil
+}
+
+// money formats an amount for display on an invoice
+func money(amount float64) string {
+	return fmt.Sprintf("$%.2f", amount)
+}
+
+// addressLines formats an address as printable lines
+func addressLines(addr Address) []string {
+	lines := []string{}
+	if addr.Street != "" {
+		lines = append(lines, addr.Street)
+	}
+	cityLine := addr.City
+	if addr.State != "" {
+		if cityLine != "" {
+			cityLine += ", "
+		}
+		cityLine += addr.State
+	}
+	cityLine = strings.TrimSpace(cityLine + " " + addr.ZipCode)
+	if cityLine != "" {
+		lines = append(lines, cityLine)
+	}
+	if addr.Country != "" {
+		lines = append(lines, addr.Country)
+	}
+	return lines
+}
+
+// invoiceTemplate renders an invoice as a standalone HTML page
+var invoiceTemplate = template.Must(template.New("invoice").Funcs(template.FuncMap{
+	"money":        money,
+	"addressLines": addressLines,
+	"lineTotal": func(item OrderItem) string {
+		return money(item.Price * float64(item.Quantity))
+	},
+	"percent": func(rate float64) string {
+		return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.3f", rate*100), "0"), ".") + "%"
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Invoice {{.Number}}</title>
+<style>
+body { font-family: Helvetica, Arial, sans-serif; margin: 40px; color: #222; }
+table { width: 100%; border-collapse: collapse; margin-top: 24px; }
+th, td { padding: 6px 8px; border-bottom: 1px solid #ddd; text-align: left; }
+td.num, th.num { text-align: right; }
+.parties { display: flex; justify-content: space-between; margin-top: 24px; }
+.totals td { border: none; }
+.grand td { font-weight: bold; border-top: 2px solid #222; }
+</style>
+</head>
+<body>
+<h1>Invoice {{.Number}}</h1>
+<p>Issued {{.IssuedAt.Format "January 2, 2006"}} &middot; Order {{.OrderID}}</p>
+<div class="parties">
+  <div>
+    <strong>{{.Seller.Name}}</strong><br>
+    {{range addressLines .Seller.Address}}{{.}}<br>{{end}}
+    {{.Seller.Email}}<br>{{.Seller.Phone}}<br>
+    {{if .Seller.TaxID}}Tax ID: {{.Seller.TaxID}}{{end}}
+  </div>
+  <div>
+    <strong>Bill to</strong><br>
+    {{.Contact.Name}}<br>
+    {{range addressLines .BillTo}}{{.}}<br>{{end}}
+    {{.Contact.Email}}{{if .Contact.Phone}}<br>{{.Contact.Phone}}{{end}}
+  </div>
+  <div>
+    <strong>Ship to</strong><br>
+    {{range addressLines .ShipTo}}{{.}}<br>{{end}}
+  </div>
+</div>
+<table>
+  <tr><th>Item</th><th class="num">Qty</th><th class="num">Unit price</th><th class="num">Amount</th></tr>
+  {{range .Lines}}<tr><td>{{.Name}}</td><td class="num">{{.Quantity}}</td><td class="num">{{money .Price}}</td><td class="num">{{lineTotal .}}</td></tr>
+  {{end}}
+</table>
+<table class="totals">
+  <tr><td></td><td class="num">Subtotal</td><td class="num">{{money .Subtotal}}</td></tr>
+  {{range .Discounts}}<tr><td></td><td class="num">Discount ({{.Code}})</td><td class="num">-{{money .Amount}}</td></tr>
+  {{end}}<tr><td></td><td class="num">Shipping</td><td class="num">{{money .ShippingCost}}</td></tr>
+  {{range .TaxLines}}<tr><td></td><td class="num">{{.Name}} ({{percent .Rate}})</td><td class="num">{{money .Amount}}</td></tr>
+  {{end}}<tr class="grand"><td></td><td class="num">Total ({{.Seller.Currency}})</td><td class="num">{{money .Total}}</td></tr>
+</table>
+</body>
+</html>
+`))
+
+// renderInvoicePDF lays out an invoice on A4 pages
+func renderInvoicePDF(invoice Invoice) []byte {
+	doc := newPDFDocument()
+	left := pdfMargin
+	right := pdfPageWidth - pdfMargin
+	y := pdfPageHeight - pdfMargin
+
+	// Header
+	doc.text(left, y-10, 22, true, "Invoice "+invoice.Number)
+	y -= 34
+	doc.text(left, y, 10, false, "Issued "+invoice.IssuedAt.Format("January 2, 2006")+"  -  Order "+invoice.OrderID)
+	y -= 28
+
+	// Seller, bill-to and ship-to columns
+	columns := [][]string{
+		append(append([]string{invoice.Seller.Name}, addressLines(invoice.Seller.Address)...),
+			invoice.Seller.Email, invoice.Seller.Phone, "Tax ID: "+invoice.Seller.TaxID),
+		append(append([]string{"Bill to", invoice.Contact.Name}, addressLines(invoice.BillTo)...),
+			invoice.Contact.Email, invoice.Contact.Phone),
+		append([]string{"Ship to"}, addressLines(invoice.ShipTo)...),
+	}
+	tallest := 0
+	for i, column := range columns {
+		for j, line := range column {
+			if line != "" && line != "Tax ID: " {
+				doc.text(left+float64(i)*170, y-float64(j)*13, 9, j == 0, line)
+			}
+		}
+		if len(column) > tallest {
+			tallest = len(column)
+		}
+	}
+	y -= float64(tallest)*13 + 20
+
+	// Line items
+	tableHeader := func() {
+		doc.text(left, y, 10, true, "Item")
+		doc.textRight(right-200, y, 10, true, "Qty")
+		doc.textRight(right-100, y, 10, true, "Unit price")
+		doc.textRight(right, y, 10, true, "Amount")
+		doc.line(left, y-5, right, y-5)
+		y -= 20
+	}
+	tableHeader()
+	for _, item := range invoice.Lines {
+		if y < pdfMargin+40 {
+			doc.addPage()
+			y = pdfPageHeight - pdfMargin
+			tableHeader()
+		}
+		doc.text(left, y, 10, false, item.Name)
+		doc.textRight(right-200, y, 10, false, fmt.Sprint(item.Quantity))
+		doc.textRight(right-100, y, 10, false, money(item.Price))
+		doc.textRight(right, y, 10, false, money(item.Price*float64(item.Quantity)))
+		y -= 16
+	}
+	doc.line(left, y+8, right, y+8)
+	y -= 10
+
+	// Totals
+	totals := [][2]string{{"Subtotal", money(invoice.Subtotal)}}
+	for _, discount := range invoice.Discounts {
+		totals = append(totals, [2]string{"Discount (" + discount.Code + ")", "-" + money(discount.Amount)})
+	}
+	totals = append(totals, [2]string{"Shipping", money(invoice.ShippingCost)})
+	for _, tax := range invoice.TaxLines {
+		totals = append(totals, [2]string{tax.Name, money(tax.Amount)})
+	}
+	for _, total := range totals {
+		if y < pdfMargin+30 {
+			doc.addPage()
+			y = pdfPageHeight - pdfMargin
+		}
+		doc.textRight(right-100, y, 10, false, total[0])
+		doc.textRight(right, y, 10, false, total[1])
+		y -= 16
+	}
+	doc.line(right-250, y+10, right, y+10)
+	y -= 6
+	doc.textRight(right-100, y, 12, true, "Total ("+invoice.Seller.Currency+")")
+	doc.textRight(right, y, 12, true, money(invoice.Total))
+
+	return doc.bytes()
+}
+
+// getInvoice renders the invoice for a customer's order as HTML, PDF or JSON
+func getInvoice(w http.ResponseWriter, r *http.Request, userID, orderID string) {
+	r, span := startSpan(r, "getInvoice", attribute.String("order.id", orderID))
+	defer span.End()
+
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Find order by ID
+	order := findOrder(r.Context(), orderID)
+
+	// Order not found
+	if order == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Order not found"})
+		return
+	}
+
+	// Verify order belongs to user
+	if order.UserID != userID {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Access denied"})
+		return
+	}
+
+	invoice, err := issueInvoice(*order)
+	if err != nil {
+		requestLogger(r).Error("seller configuration unavailable", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Seller configuration unavailable"})
+		return
+	}
+
+	// Pick format from the query string, falling back to the Accept header
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		accept := r.Header.Get("Accept")
+		switch {
+		case strings.Contains(accept, "application/pdf"):
+			format = "pdf"
+		case strings.Contains(accept, "application/json"):
+			format = "json"
+		default:
+			format = "html"
+		}
+	}
+
+	switch format {
+	case "pdf":
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", `inline; filename="`+invoice.Number+`.pdf"`)
+		w.Write(renderInvoicePDF(invoice))
+	case "html":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		invoiceTemplate.Execute(w, invoice)
+	case "json":
+		json.NewEncoder(w).Encode(invoice)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Unknown invoice format"})
+	}
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestInvoiceNumbersNeverRepeat(t *testing.T) {
+	keepStore(t)
+	keepSlice(t, &invoices)
+	keepValue(t, &lastInvoiceNumber)
+	lastInvoiceNumber = 0
+	details, err := getSeller()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := placeOrder(t, "u1", CartItem{ProductID: "p1", Quantity: 1})
+	second := placeOrder(t, "u1", CartItem{ProductID: "p2", Quantity: 1})
+
+	issued := map[string]string{}
+	for _, order := range []Order{first, second, first} {
+		invoice, err := issueInvoice(order)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if number, ok := issued[order.ID]; ok && number != invoice.Number {
+			t.Errorf("%s reissued as %s, was %s", order.ID, invoice.Number, number)
+		}
+		issued[order.ID] = invoice.Number
+	}
+	if issued[first.ID] == issued[second.ID] {
+		t.Errorf("two orders share invoice %s", issued[first.ID])
+	}
+
+	// Numbers carry on even if earlier invoices are removed
+	invoices = nil
+	third := placeOrder(t, "u1", CartItem{ProductID: "p1", Quantity: 1})
+	w := httptest.NewRecorder()
+	getInvoice(w, httptest.NewRequest("GET", "/orders/u1/"+third.ID+"/invoice?format=json", nil), "u1", third.ID)
+	var invoice Invoice
+	json.NewDecoder(w.Body).Decode(&invoice)
+	if want := details.InvoicePrefix + "000003"; invoice.Number != want {
+		t.Errorf("third invoice numbered %s, want %s", invoice.Number, want)
+	}
+
+	w = httptest.NewRecorder()
+	getInvoice(w, httptest.NewRequest("GET", "/orders/u2/"+third.ID+"/invoice", nil), "u2", third.ID)
+	if w.Code != 403 {
+		t.Errorf("another user's invoice: status %d, want 403", w.Code)
+	}
+}
@@ -0,0 +1,256 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// redacted replaces the value of sensitive log attributes
+const redacted = "[REDACTED]"
+
+// sensitiveLogKeys are attribute names whose values never reach the logs,
+// wherever they appear
+var sensitiveLogKeys = map[string]bool{
+	"password":      true,
+	"token":         true,
+	"authorization": true,
+	"cookie":        true,
+	"set-cookie":    true,
+	"secret":        true,
+}
+
+// logLevel is info until the configuration is loaded
+var logLevel = new(slog.LevelVar)
+
+// logger writes one JSON object per line to stdout
+var logger = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+	Level:       logLevel,
+	ReplaceAttr: redactAttr,
+}))
+
+// redactAttr blanks out sensitive attributes, including ones nested in groups
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	if sensitiveLogKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, redacted)
+	}
+	return a
+}
+
+// LogValue keeps the password out of the logs when a login is logged
+func (r LoginRequest) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("email", r.Email),
+		slog.String("password", redacted),
+	)
+}
+
+// LogValue keeps the password out of the logs when a user is logged
+func (u User) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("id", u.ID),
+		slog.String("email", u.Email),
+	)
+}
+
+// requestInfo is what we know about the request being served
+type requestInfo struct {
+	ID     string
+	UserID string
+	Logger *slog.Logger
+}
+
+type requestInfoKey struct{}
+
+// requestIDPattern limits incoming request IDs to something safe to log and echo
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// newRequestID generates a random request ID
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// tokenUserID reads the user from a bearer token issued at login, or ""
+// if the request has no valid token
+func tokenUserID(r *http.Request) string {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return ""
+	}
+	userID, _ := verifyToken(token, time.Now())
+	return userID
+}
+
+// statusRecorder captures the status code and size of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// beginRequest tags a request with an ID, taken from X-Request-ID when the
+// caller sent a usable one, starts the request's span, and returns a finish
+// function to defer that logs, measures and traces the outcome. When the
+// configuration is invalid it answers 503 Service Unavailable and reports
+// false, rather than serve with settings such as the token secret or the
+// rate limit missing. Every Handler starts with:
+//
+//	w, r, done, ok := beginRequest(w, r, routes)
+//	defer done()
+//	if !ok {
+//		return
+//	}
+func beginRequest(w http.ResponseWriter, r *http.Request, routes routeTable) (http.ResponseWriter, *http.Request, func(), bool) {
+	start := time.Now()
+
+	// Load the settings before anything logs, so the level applies and a bad
+	// configuration is reported on the first request
+	_, configErr := getConfig()
+	inFlight.Add(1)
+
+	requestID := r.Header.Get("X-Request-ID")
+	if !requestIDPattern.MatchString(requestID) {
+		requestID = newRequestID()
+	}
+	w.Header().Set("X-Request-ID", requestID)
+
+	// Continue the caller's trace when it sent a traceparent header
+	setupTracing()
+	// Label by the route that serves the path; anything else shares one
+	// label so IDs and scanners can't grow the metric series without bound
+	route, methods := routes.match(r.URL.Path)
+	if methods == nil {
+		route = "unmatched"
+	}
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := tracer.Start(ctx, r.Method+" "+route,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.request.method", r.Method),
+			attribute.String("http.route", route),
+			attribute.String("url.path", r.URL.Path),
+			attribute.String("request.id", requestID),
+		),
+	)
+
+	info := &requestInfo{
+		ID:     requestID,
+		UserID: tokenUserID(r),
+		Logger: logger.With("requestId", requestID, "traceId", span.SpanContext().TraceID().String()),
+	}
+	r = r.WithContext(context.WithValue(ctx, requestInfoKey{}, info))
+	recorder := &statusRecorder{ResponseWriter: w}
+
+	done := func() {
+		defer inFlight.Add(-1)
+
+		// A panic becomes a 500 rather than a dropped connection
+		if p := recover(); p != nil {
+			info.Logger.Error("panic serving request", "panic", p)
+			span.RecordError(fmt.Errorf("panic: %v", p))
+			if recorder.status == 0 {
+				recorder.WriteHeader(http.StatusInternalServerError)
+			}
+		}
+
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		level := slog.LevelInfo
+		if status >= 500 {
+			level = slog.LevelError
+		} else if status >= 400 {
+			level = slog.LevelWarn
+		}
+
+		latency := time.Since(start)
+		httpRequestDuration.Observe(latency.Seconds(), r.Method, route, strconv.Itoa(status))
+
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		if info.UserID != "" {
+			span.SetAttributes(attribute.String("user.id", info.UserID))
+		}
+		span.End()
+
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("route", route),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", status),
+			slog.Int("bytes", recorder.bytes),
+			slog.Float64("latencyMs", float64(latency.Microseconds())/1000),
+		}
+		if info.UserID != "" {
+			attrs = append(attrs, slog.String("userId", info.UserID))
+		}
+		info.Logger.LogAttrs(r.Context(), level, "request", attrs...)
+	}
+
+	if configErr != nil {
+		recorder.Header().Set("Content-Type", "application/json")
+		recorder.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(recorder).Encode(map[string]string{"error": "Service unavailable"})
+		return recorder, r, done, false
+	}
+	return recorder, r, done, true
+}
+
+// requestLogger returns the logger for a request, tagged with its ID
+func requestLogger(r *http.Request) *slog.Logger {
+	info, ok := r.Context().Value(requestInfoKey{}).(*requestInfo)
+	if !ok {
+		return logger
+	}
+	return info.Logger
+}
+
+// setRequestUser records who the request is for once a handler knows,
+// e.g. after a successful login
+func setRequestUser(r *http.Request, userID string) {
+	info, ok := r.Context().Value(requestInfoKey{}).(*requestInfo)
+	if ok {
+		info.UserID = userID
+	}
+}
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"net/http"
+
+	"learn_go/metrics"
+)
+
+// metricsRegistry holds everything served on /metrics
+var metricsRegistry = metrics.NewRegistry()
+
+// HTTP metrics, recorded for every request by beginRequest
+var httpRequestDuration = metricsRegistry.NewHistogram(
+	"http_request_duration_seconds",
+	"Time taken to serve HTTP requests.",
+	nil, "method", "route", "status",
+)
+
+// Store metrics
+var (
+	ordersCreated = metricsRegistry.NewCounter(
+		"ecommerce_orders_created_total",
+		"Orders placed successfully.",
+	)
+	checkoutFailures = metricsRegistry.NewCounter(
+		"ecommerce_checkout_failures_total",
+		"Checkouts rejected, by reason.",
+		"reason",
+	)
+	logins = metricsRegistry.NewCounter(
+		"ecommerce_logins_total",
+		"Login attempts, by result.",
+		"result",
+	)
+)
+
+// Checkout failure reasons
+const (
+	checkoutInvalidRequest     = "invalid_request"
+	checkoutInvalidAddress     = "invalid_address"
+	checkoutInvalidContact     = "invalid_contact"
+	checkoutEmptyCart          = "empty_cart"
+	checkoutProductUnavailable = "product_unavailable"
+	checkoutInsufficientStock  = "insufficient_stock"
+	checkoutInvalidCoupon      = "invalid_coupon"
+	checkoutInvalidShipping    = "invalid_shipping"
+	checkoutInternalError      = "internal_error"
+)
+
+// Handler serves the metrics for Prometheus to scrape. Scrapes aren't
+// logged or measured themselves, so they don't drown out real traffic.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	metricsRegistry.Handler().ServeHTTP(w, r)
+}
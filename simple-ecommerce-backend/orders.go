@@ -2,9 +2,14 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // OrderItem represents an item in an order
@@ -17,13 +22,25 @@ type OrderItem struct {
 
 // Order represents a customer order
 type Order struct {
-	ID           string      `json:"id"`
-	UserID       string      `json:"userId"`
-	Items        []OrderItem `json:"items"`
-	TotalAmount  float64     `json:"totalAmount"`
-	Status       string      `json:"status"`
-	CreatedAt    time.Time   `json:"createdAt"`
-	ShippingAddr Address     `json:"shippingAddress"`
+	ID             string         `json:"id"`
+	UserID         string         `json:"userId"`
+	Items          []OrderItem    `json:"items"`
+	Subtotal       float64        `json:"subtotal"`
+	CouponCode     string         `json:"couponCode,omitempty"`
+	Discounts      []DiscountLine `json:"discounts,omitempty"`
+	ShippingMethod string         `json:"shippingMethod,omitempty"`
+	ShippingCost   float64        `json:"shippingCost"`
+	TaxLines       []TaxLine      `json:"taxLines,omitempty"`
+	TaxTotal       float64        `json:"taxTotal"`
+	TotalAmount    float64        `json:"totalAmount"` // Grand total after discounts, shipping and tax
+	RefundedAmount float64        `json:"refundedAmount,omitempty"`
+	Status         string         `json:"status"`
+	CreatedAt      time.Time      `json:"createdAt"`
+	ShippingAddr   Address        `json:"shippingAddress"` // Snapshot taken at checkout
+	ShippingAddrID string         `json:"shippingAddressId,omitempty"`
+	BillingAddr    Address        `json:"billingAddress"` // Snapshot taken at checkout
+	BillingAddrID  string         `json:"billingAddressId,omitempty"`
+	Contact        ContactDetails `json:"contact"`
 }
 
 // Address represents a shipping address
@@ -35,19 +52,35 @@ type Address struct {
 	Country string `json:"country"`
 }
 
+// ContactDetails represents who to contact about an order
+type ContactDetails struct {
+	Name  string `json:"name"`
+	Phone string `json:"phone,omitempty"`
+	Email string `json:"email"`
+}
+
 // OrderRequest represents a request to create an order
 type OrderRequest struct {
-	ShippingAddr Address `json:"shippingAddress"`
+	ShippingAddr      Address        `json:"shippingAddress"`
+	ShippingAddressID string         `json:"shippingAddressId"` // Saved address to use instead of ShippingAddr
+	BillingAddr       Address        `json:"billingAddress"`
+	BillingAddressID  string         `json:"billingAddressId"` // Saved address to use instead of BillingAddr
+	Contact           ContactDetails `json:"contact"`          // Defaults to the account name and email
+	ShippingMethod    string         `json:"shippingMethod"`   // Cheapest available method if empty
 }
 
 // In-memory order database for demo purposes
 var orders = []Order{
 	{
-		ID:          "o1",
-		UserID:      "u1",
-		TotalAmount: 259.98,
-		Status:      "processing",
-		CreatedAt:   time.Now().Add(-24 * time.Hour),
+		ID:             "o1",
+		UserID:         "u1",
+		Subtotal:       259.98,
+		ShippingMethod: "standard",
+		TaxLines:       []TaxLine{{Name: "California State Tax", Rate: 0.0725, Amount: 18.85}},
+		TaxTotal:       18.85,
+		TotalAmount:    278.83,
+		Status:         "processing",
+		CreatedAt:      time.Now().Add(-24 * time.Hour),
 		Items: []OrderItem{
 			{
 				ProductID: "p1",
@@ -63,19 +96,85 @@ var orders = []Order{
 			ZipCode: "12345",
 			Country: "USA",
 		},
+		BillingAddr: Address{
+			Street:  "123 Main St",
+			City:    "Anytown",
+			State:   "CA",
+			ZipCode: "12345",
+			Country: "USA",
+		},
+		Contact: ContactDetails{
+			Name:  "John Doe",
+			Email: "john@example.com",
+		},
+	},
+	{
+		ID:             "o2",
+		UserID:         "u1",
+		Subtotal:       49.99,
+		ShippingMethod: "standard",
+		ShippingCost:   5.99,
+		TaxLines:       []TaxLine{{Name: "California State Tax", Rate: 0.0725, Amount: 3.62}},
+		TaxTotal:       3.62,
+		TotalAmount:    59.6,
+		Status:         "delivered",
+		CreatedAt:      time.Now().Add(-10 * 24 * time.Hour),
+		Items: []OrderItem{
+			{
+				ProductID: "p2",
+				Name:      "Wireless Mouse",
+				Price:     49.99,
+				Quantity:  1,
+			},
+		},
+		ShippingAddr: Address{
+			Street:  "123 Main St",
+			City:    "Anytown",
+			State:   "CA",
+			ZipCode: "12345",
+			Country: "USA",
+		},
+		BillingAddr: Address{
+			Street:  "123 Main St",
+			City:    "Anytown",
+			State:   "CA",
+			ZipCode: "12345",
+			Country: "USA",
+		},
+		Contact: ContactDetails{
+			Name:  "John Doe",
+			Email: "john@example.com",
+		},
 	},
 }
 
+// orderRoutes lists the routes Handler serves, for CORS preflights and metrics
+var orderRoutes = routeTable{
+	"/orders/{id}":                {"GET", "POST"},
+	"/orders/{id}/{id}":           {"GET"},
+	"/orders/{id}/{id}/returns":   {"GET", "POST"},
+	"/orders/{id}/{id}/invoice":   {"GET"},
+	"/orders/{id}/{id}/shipments": {"GET"},
+}
+
 // Handler processes order-related requests
 func Handler(w http.ResponseWriter, r *http.Request) {
-	// Set CORS headers
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+	// Tag the request with an ID and log it when done
+	w, r, done, ok := beginRequest(w, r, orderRoutes)
+	defer done()
 	
-	// Handle preflight requests
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
+	// Refuse to serve with an invalid configuration
+	if !ok {
+		return
+	}
+	
+	// Add CORS headers and answer preflight requests
+	if handleCORS(w, r, orderRoutes) {
+		return
+	}
+
+	// Turn away clients over the rate limit
+	if rateLimited(w, r) {
 		return
 	}
 
@@ -99,14 +198,33 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 	// Handle specific order
 	if len(pathParts) > 3 && pathParts[3] != "" {
 		orderID := pathParts[3]
-		getOrder(w, userID, orderID)
+		
+		// Handle returns endpoint
+		if len(pathParts) > 4 && pathParts[4] == "returns" {
+			handleOrderReturns(w, r, userID, orderID)
+			return
+		}
+		
+		// Handle invoice endpoint
+		if len(pathParts) > 4 && pathParts[4] == "invoice" {
+			getInvoice(w, r, userID, orderID)
+			return
+		}
+		
+		// Handle shipments endpoint
+		if len(pathParts) > 4 && pathParts[4] == "shipments" {
+			getOrderShipments(w, r, userID, orderID)
+			return
+		}
+		
+		getOrder(w, r, userID, orderID)
 		return
 	}
 	
 	// Handle different methods
 	switch r.Method {
 	case "GET":
-		getOrders(w, userID)
+		getOrders(w, r, userID)
 	case "POST":
 		createOrder(w, r, userID)
 	default:
@@ -115,28 +233,20 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 }
 
 // getOrders returns all orders for a user
-func getOrders(w http.ResponseWriter, userID string) {
-	// Find orders for user
-	var userOrders []Order
-	for _, order := range orders {
-		if order.UserID == userID {
-			userOrders = append(userOrders, order)
-		}
-	}
+func getOrders(w http.ResponseWriter, r *http.Request, userID string) {
+	r, span := startSpan(r, "getOrders", attribute.String("user.id", userID))
+	defer span.End()
 	
-	json.NewEncoder(w).Encode(userOrders)
+	json.NewEncoder(w).Encode(userOrders(r.Context(), userID))
 }
 
 // getOrder returns a specific order
-func getOrder(w http.ResponseWriter, userID, orderID string) {
+func getOrder(w http.ResponseWriter, r *http.Request, userID, orderID string) {
+	r, span := startSpan(r, "getOrder", attribute.String("order.id", orderID))
+	defer span.End()
+	
 	// Find order by ID
-	var order *Order
-	for i := range orders {
-		if orders[i].ID == orderID {
-			order = &orders[i]
-			break
-		}
-	}
+	order := findOrder(r.Context(), orderID)
 	
 	// Order not found
 	if order == nil {
@@ -157,49 +267,141 @@ func getOrder(w http.ResponseWriter, userID, orderID string) {
 
 // createOrder creates a new order from the user's cart
 func createOrder(w http.ResponseWriter, r *http.Request, userID string) {
+	r, span := startSpan(r, "createOrder", attribute.String("user.id", userID))
+	defer span.End()
+	
 	var req OrderRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
+		checkoutFailures.Inc(checkoutInvalidRequest)
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 	
-	// Find user's cart
-	var cart *Cart
-	for i := range carts {
-		if carts[i].UserID == userID {
-			cart = &carts[i]
-			break
+	// Resolve saved address, falling back to the default shipping address
+	if req.ShippingAddressID != "" {
+		saved := findSavedAddress(userID, req.ShippingAddressID)
+		if saved == nil {
+			checkoutFailures.Inc(checkoutInvalidAddress)
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Address not found"})
+			return
+		}
+		req.ShippingAddr = saved.Address
+	} else if req.ShippingAddr == (Address{}) {
+		if saved := defaultAddress(userID, false); saved != nil {
+			req.ShippingAddressID = saved.ID
+			req.ShippingAddr = saved.Address
+		}
+	}
+	
+	// Resolve billing address, falling back to the default billing address
+	// and then to the shipping address
+	if req.BillingAddressID != "" {
+		saved := findSavedAddress(userID, req.BillingAddressID)
+		if saved == nil {
+			checkoutFailures.Inc(checkoutInvalidAddress)
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Billing address not found"})
+			return
+		}
+		req.BillingAddr = saved.Address
+	} else if req.BillingAddr == (Address{}) {
+		if saved := defaultAddress(userID, true); saved != nil {
+			req.BillingAddressID = saved.ID
+			req.BillingAddr = saved.Address
+		} else {
+			req.BillingAddressID = req.ShippingAddressID
+			req.BillingAddr = req.ShippingAddr
 		}
 	}
 	
+	// Contact details default to the account
+	if user := findUser(r.Context(), userID); user != nil {
+		if req.Contact.Name == "" {
+			req.Contact.Name = user.Name
+		}
+		if req.Contact.Email == "" {
+			req.Contact.Email = user.Email
+		}
+	}
+	
+	// Validate addresses and contact details
+	err = validateAddress(req.ShippingAddr)
+	if err != nil {
+		checkoutFailures.Inc(checkoutInvalidAddress)
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Shipping address: " + err.Error()})
+		return
+	}
+	err = validateAddress(req.BillingAddr)
+	if err != nil {
+		checkoutFailures.Inc(checkoutInvalidAddress)
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Billing address: " + err.Error()})
+		return
+	}
+	if strings.TrimSpace(req.Contact.Name) == "" {
+		checkoutFailures.Inc(checkoutInvalidContact)
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Contact name required"})
+		return
+	}
+	err = validateEmail(req.Contact.Email)
+	if err != nil {
+		checkoutFailures.Inc(checkoutInvalidContact)
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if req.Contact.Phone != "" {
+		err = validatePhone(req.Contact.Phone, req.BillingAddr.Country)
+		if err != nil {
+			checkoutFailures.Inc(checkoutInvalidContact)
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+	}
+	
+	// Find user's cart
+	cart := findCart(r.Context(), userID)
+	
 	// Cart not found or empty
 	if cart == nil || len(cart.Items) == 0 {
+		checkoutFailures.Inc(checkoutEmptyCart)
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Cart is empty"})
 		return
 	}
 	
-	// Create order items and calculate total
+	// Create order items and calculate total, locking in the price that
+	// applies right now
 	var orderItems []OrderItem
 	var totalAmount float64
+	now := time.Now()
 	
 	for _, item := range cart.Items {
 		// Find product details
-		var product *Product
-		for i := range products {
-			if products[i].ID == item.ProductID {
-				product = &products[i]
-				break
-			}
-		}
+		product := findProduct(r.Context(), item.ProductID)
 		
-		if product == nil {
-			continue // Skip if product not found
+		// Archived products stay in carts but can't be bought
+		if product == nil || product.ArchivedAt != nil {
+			name := item.ProductID
+			if product != nil {
+				name = product.Name
+			}
+			checkoutFailures.Inc(checkoutProductUnavailable)
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": name + " is no longer available; remove it from your cart",
+			})
+			return
 		}
 		
 		// Check stock
 		if product.Stock < item.Quantity {
+			checkoutFailures.Inc(checkoutInsufficientStock)
 			w.WriteHeader(http.StatusBadRequest)
 			json.NewEncoder(w).Encode(map[string]string{
 				"error": "Not enough stock for " + product.Name,
@@ -208,40 +410,134 @@ func createOrder(w http.ResponseWriter, r *http.Request, userID string) {
 		}
 		
 		// Add to order items
+		price := effectivePrice(*product, now)
 		orderItems = append(orderItems, OrderItem{
 			ProductID: product.ID,
 			Name:      product.Name,
-			Price:     product.Price,
+			Price:     price,
 			Quantity:  item.Quantity,
 		})
 		
 		// Update total
-		totalAmount += product.Price * float64(item.Quantity)
+		totalAmount += price * float64(item.Quantity)
+	}
+	
+	// Generate a simple ID (in production, use UUID)
+	orderID := "o" + strconv.Itoa(len(orders)+1)
+	
+	// Apply coupon, checking it is still valid at checkout
+	subtotal := roundCents(totalAmount)
+	var discounts []DiscountLine
+	var freeShipping bool
+	var promo *Promotion
+	if cart.CouponCode != "" {
+		promo = findPromotion(cart.CouponCode)
+		if promo == nil {
+			err = errors.New("Coupon not found")
+		} else {
+			err = checkPromotion(promo, userID, subtotal, now)
+		}
+		if err != nil {
+			checkoutFailures.Inc(checkoutInvalidCoupon)
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Coupon " + cart.CouponCode + " cannot be applied: " + err.Error(),
+			})
+			return
+		}
 		
-		// Update stock
-		product.Stock -= item.Quantity
+		discounts, freeShipping = applyPromotion(promo, orderItems)
+		for _, discount := range discounts {
+			totalAmount -= discount.Amount
+		}
+		totalAmount = roundCents(math.Max(totalAmount, 0))
+	}
+	
+	// Price the chosen shipping method
+	quotes, err := quoteShipping(req.ShippingAddr, orderItems, totalAmount, freeShipping)
+	if err != nil {
+		requestLogger(r).Error("shipping configuration unavailable", "error", err)
+		checkoutFailures.Inc(checkoutInternalError)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Shipping configuration unavailable"})
+		return
+	}
+	shipping, err := chooseShipping(quotes, req.ShippingMethod)
+	if err != nil {
+		checkoutFailures.Inc(checkoutInvalidShipping)
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	totalAmount = roundCents(totalAmount + shipping.Cost)
+	
+	// Calculate tax on the discounted lines
+	calculator, err := getTaxCalculator()
+	var taxLines []TaxLine
+	if err == nil {
+		taxLines, err = calculator.Calculate(req.ShippingAddr, taxableLines(orderItems, discounts))
+	}
+	if err != nil {
+		requestLogger(r).Error("tax calculation failed", "error", err)
+		checkoutFailures.Inc(checkoutInternalError)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Tax calculation failed"})
+		return
+	}
+	var taxTotal float64
+	for _, line := range taxLines {
+		taxTotal += line.Amount
+	}
+	taxTotal = roundCents(taxTotal)
+	totalAmount = roundCents(totalAmount + taxTotal)
+	
+	// Record coupon redemption
+	if promo != nil {
+		promotionUsages = append(promotionUsages, PromotionUsage{
+			PromotionID: promo.ID,
+			UserID:      userID,
+			OrderID:     orderID,
+			UsedAt:      now,
+		})
+	}
+	
+	// Record sales in the inventory ledger once every item is in stock
+	for _, item := range orderItems {
+		recordInventory(r.Context(), item.ProductID, InventorySale, -item.Quantity, "", orderID)
 	}
 	
 	// Create new order
 	newOrder := Order{
-		ID:           "o" + string(len(orders)+1),
-		UserID:       userID,
-		Items:        orderItems,
-		TotalAmount:  totalAmount,
-		Status:       "pending",
-		CreatedAt:    time.Now(),
-		ShippingAddr: req.ShippingAddr,
+		ID:             orderID,
+		UserID:         userID,
+		Items:          orderItems,
+		Subtotal:       subtotal,
+		CouponCode:     cart.CouponCode,
+		Discounts:      discounts,
+		ShippingMethod: shipping.MethodID,
+		ShippingCost:   shipping.Cost,
+		TaxLines:       taxLines,
+		TaxTotal:       taxTotal,
+		TotalAmount:    totalAmount,
+		Status:         "pending",
+		CreatedAt:      now,
+		ShippingAddr:   req.ShippingAddr,
+		ShippingAddrID: req.ShippingAddressID,
+		BillingAddr:    req.BillingAddr,
+		BillingAddrID:  req.BillingAddressID,
+		Contact:        req.Contact,
 	}
 	
 	// Add to orders
-	orders = append(orders, newOrder)
+	saveOrder(r.Context(), newOrder)
+	span.SetAttributes(attribute.String("order.id", newOrder.ID))
+	ordersCreated.Inc()
+	requestLogger(r).Info("order created", "orderId", newOrder.ID, "total", newOrder.TotalAmount)
 	
 	// Clear cart
-	for i := range carts {
-		if carts[i].UserID == userID {
-			carts[i].Items = []CartItem{}
-			break
-		}
+	if cart := findCart(r.Context(), userID); cart != nil {
+		cart.Items = []CartItem{}
+		cart.CouponCode = ""
 	}
 	
 	w.WriteHeader(http.StatusCreated)
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// productETag is the entity tag for a product version
+func productETag(product Product) string {
+	return `"` + product.ID + "-v" + strconv.Itoa(product.Version) + `"`
+}
+
+// ifMatch reports whether a write may proceed. Requests without If-Match
+// always may; otherwise one of the listed tags must be the current one.
+func ifMatch(r *http.Request, product Product) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return true
+	}
+	current := productETag(product)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == current {
+			return true
+		}
+	}
+	return false
+}
+
+// isMergePatch reports whether a PATCH body is a JSON Merge Patch. Plain
+// JSON is accepted too, since that is what most clients send.
+func isMergePatch(r *http.Request) bool {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/merge-patch+json" || mediaType == "application/json"
+}
+
+// mergePatch applies a JSON Merge Patch (RFC 7386) to a decoded JSON
+// document: objects are merged key by key, null removes a key, and
+// anything else replaces the target outright.
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+		} else {
+			targetObject[key] = mergePatch(targetObject[key], value)
+		}
+	}
+	return targetObject
+}
+
+// patchProduct applies a merge patch body to a product. Unknown fields are
+// rejected so typos don't silently do nothing.
+func patchProduct(product Product, body io.Reader) (Product, error) {
+	var patch interface{}
+	err := json.NewDecoder(body).Decode(&patch)
+	if err != nil {
+		return product, err
+	}
+
+	original, err := json.Marshal(product)
+	if err != nil {
+		return product, err
+	}
+	var document interface{}
+	err = json.Unmarshal(original, &document)
+	if err != nil {
+		return product, err
+	}
+
+	merged, err := json.Marshal(mergePatch(document, patch))
+	if err != nil {
+		return product, err
+	}
+	var patched Product
+	decoder := json.NewDecoder(bytes.NewReader(merged))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(&patched)
+	return patched, err
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		target interface{}
+		patch  interface{}
+		want   interface{}
+	}{
+		{
+			"replaces a value",
+			map[string]interface{}{"name": "A", "price": 1.0},
+			map[string]interface{}{"price": 2.0},
+			map[string]interface{}{"name": "A", "price": 2.0},
+		},
+		{
+			"null removes a key",
+			map[string]interface{}{"name": "A", "description": "B"},
+			map[string]interface{}{"description": nil},
+			map[string]interface{}{"name": "A"},
+		},
+		{
+			"merges nested objects",
+			map[string]interface{}{"dimensions": map[string]interface{}{"lengthCm": 1.0, "widthCm": 2.0}},
+			map[string]interface{}{"dimensions": map[string]interface{}{"widthCm": 3.0}},
+			map[string]interface{}{"dimensions": map[string]interface{}{"lengthCm": 1.0, "widthCm": 3.0}},
+		},
+		{
+			"non-object patch replaces the target",
+			map[string]interface{}{"name": "A"},
+			"B",
+			"B",
+		},
+	}
+	for _, tc := range tests {
+		if got := mergePatch(tc.target, tc.patch); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestPatchProduct(t *testing.T) {
+	product := Product{ID: "p1", Name: "A", Description: "B", Price: 1, Version: 3}
+
+	patched, err := patchProduct(product, strings.NewReader(`{"description":null,"price":2}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patched.Description != "" || patched.Price != 2 || patched.Name != "A" {
+		t.Errorf("patched = %+v", patched)
+	}
+
+	_, err = patchProduct(product, strings.NewReader(`{"prise":2}`))
+	if err == nil || !strings.Contains(err.Error(), "prise") {
+		t.Errorf("unknown field: err = %v", err)
+	}
+}
+
+func TestProductWritePreconditions(t *testing.T) {
+	keepStore(t)
+	current := productETag(products[0])
+
+	tests := []struct {
+		name        string
+		method      string
+		ifMatch     string
+		contentType string
+		body        string
+		want        int
+	}{
+		{"stale PUT", "PUT", `"p1-v0"`, "", `{"name":"Y","price":10}`, 412},
+		{"stale PATCH", "PATCH", `"p1-v0"`, "", `{"price":10}`, 412},
+		{"stale DELETE", "DELETE", `"p1-v0"`, "", "", 412},
+		{"PATCH with another media type", "PATCH", "", "text/plain", `{"price":10}`, 415},
+		{"PATCH with an unknown field", "PATCH", "", "application/merge-patch+json", `{"prise":10}`, 400},
+		{"PATCH with one of several tags", "PATCH", `"p1-v0", ` + current, "application/merge-patch+json", `{"price":10}`, 200},
+		{"PATCH with any tag", "PATCH", "*", "application/json", `{"price":11}`, 200},
+	}
+	for _, tc := range tests {
+		r := httptest.NewRequest(tc.method, "/products/p1", strings.NewReader(tc.body))
+		if tc.ifMatch != "" {
+			r.Header.Set("If-Match", tc.ifMatch)
+		}
+		if tc.contentType != "" {
+			r.Header.Set("Content-Type", tc.contentType)
+		}
+		w := httptest.NewRecorder()
+		handleSingleProduct(w, r, "p1")
+
+		if w.Code != tc.want {
+			t.Errorf("%s: status %d, want %d: %s", tc.name, w.Code, tc.want, w.Body)
+		}
+		if tc.want == 412 && w.Header().Get("ETag") == "" {
+			t.Errorf("%s: 412 without the current ETag", tc.name)
+		}
+	}
+
+	if products[0].Price != 11 || products[0].Version != 3 {
+		t.Errorf("price %v, version %d; want the two successful patches applied", products[0].Price, products[0].Version)
+	}
+}
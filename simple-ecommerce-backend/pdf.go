@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A4 page size and margins in PDF points
+const (
+	pdfPageWidth  = 595.0
+	pdfPageHeight = 842.0
+	pdfMargin     = 50.0
+)
+
+// pdfDocument is a minimal PDF writer for text-based documents such as
+// invoices. It only supports the built-in Helvetica fonts, so no font
+// files need to be embedded.
+type pdfDocument struct {
+	pages []*bytes.Buffer
+}
+
+// newPDFDocument creates a document with one empty page
+func newPDFDocument() *pdfDocument {
+	doc := &pdfDocument{}
+	doc.addPage()
+	return doc
+}
+
+// addPage starts a new page; subsequent drawing goes on it
+func (d *pdfDocument) addPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+}
+
+// page returns the content stream of the current page
+func (d *pdfDocument) page() *bytes.Buffer {
+	return d.pages[len(d.pages)-1]
+}
+
+// text draws a string with its baseline at (x, y), measured from the bottom left
+func (d *pdfDocument) text(x, y, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(d.page(), "BT /%s %s Tf %s %s Td (%s) Tj ET\n",
+		font, pdfNumber(size), pdfNumber(x), pdfNumber(y), pdfEscape(s))
+}
+
+// textRight draws a string ending at x, for right-aligned columns
+func (d *pdfDocument) textRight(x, y, size float64, bold bool, s string) {
+	d.text(x-pdfTextWidth(s, size), y, size, bold, s)
+}
+
+// line draws a thin line between two points
+func (d *pdfDocument) line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(d.page(), "0.5 w %s %s m %s %s l S\n",
+		pdfNumber(x1), pdfNumber(y1), pdfNumber(x2), pdfNumber(y2))
+}
+
+// bytes renders the document as a PDF file
+func (d *pdfDocument) bytes() []byte {
+	var out bytes.Buffer
+	var offsets []int
+
+	// Objects are numbered from 1: catalog, page tree, two fonts,
+	// then a page and its content stream for every page
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = strconv.Itoa(5+i*2) + " 0 R"
+	}
+
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, content := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] "+
+			"/Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfNumber(pdfPageWidth), pdfNumber(pdfPageHeight), 6+i*2))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return out.Bytes()
+}
+
+// pdfNumber formats a coordinate without needless decimals
+func pdfNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// winAnsiExtras maps the characters WinAnsiEncoding places at 0x80-0x9F,
+// where Latin-1 has control characters, to their codes
+var winAnsiExtras = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'ˆ': 0x88, '‰': 0x89, 'Š': 0x8A, '‹': 0x8B, 'Œ': 0x8C, 'Ž': 0x8E,
+	'‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+	'˜': 0x98, '™': 0x99, 'š': 0x9A, '›': 0x9B, 'œ': 0x9C, 'ž': 0x9E, 'Ÿ': 0x9F,
+}
+
+// pdfEscape makes a string safe inside a PDF literal string, encoded for
+// the fonts' WinAnsiEncoding. Characters it can't show become '?'.
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 32:
+			b.WriteByte(' ')
+		case r < 127 || (r >= 0xA0 && r < 256):
+			// ASCII and the upper half of Latin-1 are the same in WinAnsi
+			b.WriteByte(byte(r))
+		case winAnsiExtras[r] != 0:
+			b.WriteByte(winAnsiExtras[r])
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// pdfTextWidth estimates the width of a string in Helvetica. Digits and
+// most punctuation are exact; letters use an average width, which is
+// close enough for aligning columns of numbers.
+func pdfTextWidth(s string, size float64) float64 {
+	var units float64
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9', r == '$':
+			units += 556
+		case r == '.' || r == ',' || r == ' ':
+			units += 278
+		case r == '-':
+			units += 333
+		case r == '%':
+			units += 889
+		default:
+			units += 556
+		}
+	}
+	return units * size / 1000
+}
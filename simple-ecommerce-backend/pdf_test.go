@@ -0,0 +1,24 @@
+package handler
+
+import "testing"
+
+func TestPDFEscapeUsesWinAnsi(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Total (net)", `Total \(net\)`},
+		{`C:\`, `C:\\`},
+		{"line\nbreak", "line break"},
+		{"café £5", "caf\xe9 \xa35"},
+		{"€10 – “thanks”", "\x8010 \x96 \x93thanks\x94"},
+		{"\u0080\u0093", "??"}, // Latin-1 controls aren't WinAnsi's curly quotes
+		{"\x7f", "?"},
+		{"日本", "??"},
+	}
+	for _, tc := range tests {
+		if got := pdfEscape(tc.in); got != tc.want {
+			t.Errorf("pdfEscape(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// PriceRule sets a product's price for a window of time, for sales and
+// scheduled price changes. Product.Price stays the list price; rules are
+// applied whenever a price is read, so nothing needs editing when a sale
+// starts or ends.
+type PriceRule struct {
+	ID        string     `json:"id"`
+	ProductID string     `json:"productId"`
+	Label     string     `json:"label,omitempty"` // e.g. "Weekend sale"
+	Price     float64    `json:"price"`           // Price charged while the rule is active
+	StartsAt  time.Time  `json:"startsAt"`
+	EndsAt    *time.Time `json:"endsAt,omitempty"` // Nil for a permanent price change
+
+	// CompareAtPrice is shown struck through next to the price. It defaults
+	// to the list price, or for a sale to the regular price in force; set it
+	// to the new price to show no comparison.
+	CompareAtPrice *float64 `json:"compareAtPrice,omitempty"`
+
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// PricedProduct is a product with the price that applies right now
+type PricedProduct struct {
+	Product
+	EffectivePrice float64    `json:"effectivePrice"`
+	CompareAtPrice float64    `json:"compareAtPrice,omitempty"` // Only when higher than the effective price
+	SaleLabel      string     `json:"saleLabel,omitempty"`
+	SaleEndsAt     *time.Time `json:"saleEndsAt,omitempty"`
+}
+
+// In-memory price rule database for demo purposes
+var priceRules = []PriceRule{}
+
+// nextPriceRuleID counts every rule ever created so deleted IDs aren't reused
+var nextPriceRuleID = 1
+
+// activePriceRule returns the rule in force for a product at a time. A sale
+// (a rule with an end) overrides a permanent price change, and among rules
+// of the same kind the one that started last wins, so each scheduled price
+// change replaces the one before it.
+func activePriceRule(productID string, now time.Time) *PriceRule {
+	if sale := latestPriceRule(productID, now, true); sale != nil {
+		return sale
+	}
+	return latestPriceRule(productID, now, false)
+}
+
+// latestPriceRule returns the most recently started sale, or permanent
+// price change, in force for a product at a time. Rules starting at the
+// same moment go to the one created last.
+func latestPriceRule(productID string, now time.Time, sale bool) *PriceRule {
+	var latest *PriceRule
+	for i := range priceRules {
+		rule := &priceRules[i]
+		if rule.ProductID != productID || (rule.EndsAt != nil) != sale || now.Before(rule.StartsAt) {
+			continue
+		}
+		if rule.EndsAt != nil && !now.Before(*rule.EndsAt) {
+			continue
+		}
+		if latest == nil || !rule.StartsAt.Before(latest.StartsAt) {
+			latest = rule
+		}
+	}
+	return latest
+}
+
+// regularPrice is what a product costs at a time leaving sales aside: the
+// latest permanent price change, or else the list price
+func regularPrice(product Product, now time.Time) float64 {
+	rule := latestPriceRule(product.ID, now, false)
+	if rule == nil {
+		return product.Price
+	}
+	return rule.Price
+}
+
+// effectivePrice is what a product costs at a time
+func effectivePrice(product Product, now time.Time) float64 {
+	rule := activePriceRule(product.ID, now)
+	if rule == nil {
+		return product.Price
+	}
+	return rule.Price
+}
+
+// priceProduct works out the current price of a product for display
+func priceProduct(product Product, now time.Time) PricedProduct {
+	priced := PricedProduct{Product: product, EffectivePrice: product.Price}
+
+	rule := activePriceRule(product.ID, now)
+	if rule == nil {
+		return priced
+	}
+
+	priced.EffectivePrice = rule.Price
+	priced.SaleLabel = rule.Label
+	priced.SaleEndsAt = rule.EndsAt
+
+	// A sale is compared with the regular price it interrupts
+	compareAt := product.Price
+	if rule.EndsAt != nil {
+		compareAt = regularPrice(product, now)
+	}
+	if rule.CompareAtPrice != nil {
+		compareAt = *rule.CompareAtPrice
+	}
+	if compareAt > rule.Price {
+		priced.CompareAtPrice = compareAt
+	}
+	return priced
+}
+
+// priceProducts prices a list of products at the same instant
+func priceProducts(list []Product) []PricedProduct {
+	now := time.Now()
+	priced := make([]PricedProduct, 0, len(list))
+	for _, product := range list {
+		priced = append(priced, priceProduct(product, now))
+	}
+	return priced
+}
+
+// handlePriceRules processes price rule requests for a product
+func handlePriceRules(w http.ResponseWriter, r *http.Request, productID string, pathParts []string) {
+	r, span := startSpan(r, "handlePriceRules", attribute.String("product.id", productID))
+	defer span.End()
+
+	// Find product by ID
+	product := findProduct(r.Context(), productID)
+
+	// Product not found
+	if product == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Product not found"})
+		return
+	}
+
+	// DELETE /products/{id}/prices/{ruleId} - Cancel a rule
+	if len(pathParts) > 4 && pathParts[4] != "" {
+		if r.Method != "DELETE" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		found := false
+		var newRules []PriceRule
+		for _, rule := range priceRules {
+			if rule.ID == pathParts[4] && rule.ProductID == productID {
+				found = true
+				continue
+			}
+			newRules = append(newRules, rule)
+		}
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Price rule not found"})
+			return
+		}
+		priceRules = newRules
+
+		json.NewEncoder(w).Encode(map[string]string{"message": "Price rule deleted"})
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		// Current price plus every past, current and upcoming rule
+		rules := []PriceRule{}
+		for _, rule := range priceRules {
+			if rule.ProductID == productID {
+				rules = append(rules, rule)
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"current": priceProduct(*product, time.Now()),
+			"rules":   rules,
+		})
+	case "POST":
+		createPriceRule(w, r, *product)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// createPriceRule schedules a sale or price change for a product
+func createPriceRule(w http.ResponseWriter, r *http.Request, product Product) {
+	var rule PriceRule
+	err := json.NewDecoder(r.Body).Decode(&rule)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var problems []string
+	if rule.Price <= 0 {
+		problems = append(problems, "price must be positive")
+	}
+	if rule.CompareAtPrice != nil && *rule.CompareAtPrice < 0 {
+		problems = append(problems, "compareAtPrice cannot be negative")
+	}
+	if rule.StartsAt.IsZero() {
+		rule.StartsAt = time.Now()
+	}
+	if rule.EndsAt != nil && !rule.EndsAt.After(rule.StartsAt) {
+		problems = append(problems, "endsAt must be after startsAt")
+	}
+	if rule.EndsAt != nil && rule.EndsAt.Before(time.Now()) {
+		problems = append(problems, "endsAt is in the past")
+	}
+	if len(problems) > 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": strings.Join(problems, "; ")})
+		return
+	}
+
+	rule.ID = "pr" + strconv.Itoa(nextPriceRuleID)
+	nextPriceRuleID++
+	rule.ProductID = product.ID
+	rule.Label = strings.TrimSpace(rule.Label)
+	rule.CreatedAt = time.Now()
+	priceRules = append(priceRules, rule)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rule)
+}
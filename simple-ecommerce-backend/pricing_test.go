@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestActivePriceRule(t *testing.T) {
+	keepStore(t)
+	monday := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	saleEnds := monday.Add(3 * day)
+	priceRules = []PriceRule{
+		{ID: "pr1", ProductID: "p1", Price: 90, StartsAt: monday},
+		{ID: "pr2", ProductID: "p1", Price: 110, StartsAt: monday.Add(4 * day)},
+		{ID: "pr3", ProductID: "p1", Label: "Sale", Price: 99, StartsAt: monday.Add(2 * day), EndsAt: &saleEnds},
+		{ID: "pr4", ProductID: "p2", Price: 1, StartsAt: monday},
+	}
+	product := Product{ID: "p1", Price: 100}
+
+	tests := []struct {
+		name      string
+		at        time.Time
+		price     float64
+		compareAt float64
+	}{
+		{"before any rule", monday.Add(-time.Nanosecond), 100, 0},
+		{"a rule applies from its start", monday, 90, 100},
+		{"a sale overrides a permanent change", monday.Add(2 * day), 99, 0},
+		{"a sale ends at its end", saleEnds, 90, 100},
+		{"the latest permanent change wins", monday.Add(4 * day), 110, 0},
+		{"even when it costs more", monday.Add(30 * day), 110, 0},
+	}
+	for _, tc := range tests {
+		priced := priceProduct(product, tc.at)
+		if priced.EffectivePrice != tc.price || priced.CompareAtPrice != tc.compareAt {
+			t.Errorf("%s: price %v, compare at %v; want %v, %v",
+				tc.name, priced.EffectivePrice, priced.CompareAtPrice, tc.price, tc.compareAt)
+		}
+		if effectivePrice(product, tc.at) != tc.price {
+			t.Errorf("%s: effectivePrice disagrees with priceProduct", tc.name)
+		}
+	}
+
+	// A sale compares with the regular price it interrupts
+	priceRules[0].Price = 120
+	if priced := priceProduct(product, monday.Add(2*day)); priced.CompareAtPrice != 120 {
+		t.Errorf("sale during a raised price: compare at %v, want 120", priced.CompareAtPrice)
+	}
+}
+
+func TestCheckoutLocksThePrice(t *testing.T) {
+	keepStore(t)
+	ends := time.Now().Add(time.Hour)
+	priceRules = []PriceRule{{ID: "pr1", ProductID: "p2", Price: 39.99, StartsAt: time.Now().Add(-time.Hour), EndsAt: &ends}}
+
+	order := placeOrder(t, "u1", CartItem{ProductID: "p2", Quantity: 2})
+	if order.Items[0].Price != 39.99 || order.Subtotal != 79.98 {
+		t.Fatalf("order priced at %v, subtotal %v; want the sale price", order.Items[0].Price, order.Subtotal)
+	}
+
+	// The sale ending doesn't reprice the order
+	priceRules = nil
+	saved := findOrder(t.Context(), order.ID)
+	if saved == nil || saved.Items[0].Price != 39.99 {
+		t.Errorf("stored order repriced: %+v", saved)
+	}
+}
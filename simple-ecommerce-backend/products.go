@@ -4,16 +4,47 @@ import (
 	"encoding/json"
 	"net/http"
 	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Product represents an item in our store
 type Product struct {
 	ID          string  `json:"id"`
+	SKU         string  `json:"sku,omitempty"`
 	Name        string  `json:"name"`
 	Description string  `json:"description"`
 	Price       float64 `json:"price"`
 	ImageURL    string  `json:"imageUrl"`
 	Stock       int     `json:"stock"`
+	
+	// Uploaded images in display order; ImageURL mirrors the first one
+	Images []ProductImage `json:"images,omitempty"`
+	
+	// LowStockThreshold emits a low-stock event when stock drops to or below it (0 disables)
+	LowStockThreshold int `json:"lowStockThreshold,omitempty"`
+	
+	// TaxCategory selects the tax rates that apply (standard, reduced or exempt)
+	TaxCategory string `json:"taxCategory,omitempty"`
+	
+	// Packed weight and size, used to rate shipping
+	WeightKg   float64    `json:"weightKg"`
+	Dimensions Dimensions `json:"dimensions"`
+	
+	// Average rating and count of published reviews, kept up to date by the review endpoints
+	RatingAverage float64 `json:"ratingAverage"`
+	RatingCount   int     `json:"ratingCount"`
+	
+	// Version goes up with every catalog edit and is exposed as the ETag.
+	// Stock movements from orders and returns don't change it; edits can't
+	// set stock, so a write with an old ETag can't undo them either.
+	Version int `json:"version"`
+	
+	// ArchivedAt is set when the product is deleted. Archived products are
+	// hidden from the catalog and can't be bought, but still resolve by ID
+	// for past orders and existing carts.
+	ArchivedAt *time.Time `json:"archivedAt,omitempty"`
 }
 
 // In-memory product database for demo purposes
@@ -21,40 +52,84 @@ type Product struct {
 var products = []Product{
 	{
 		ID:          "p1",
+		SKU:         "KB-MECH-01",
 		Name:        "Mechanical Keyboard",
 		Description: "Premium mechanical keyboard with RGB lighting",
 		Price:       129.99,
 		ImageURL:    "https://example.com/keyboard.jpg",
 		Stock:       50,
+		
+		LowStockThreshold: 10,
+		WeightKg:          1.2,
+		Dimensions:        Dimensions{LengthCm: 46, WidthCm: 16, HeightCm: 5},
+		Version:           1,
 	},
 	{
 		ID:          "p2",
+		SKU:         "MS-WL-01",
 		Name:        "Wireless Mouse",
 		Description: "Ergonomic wireless mouse with long battery life",
 		Price:       49.99,
 		ImageURL:    "https://example.com/mouse.jpg",
 		Stock:       100,
+		
+		LowStockThreshold: 20,
+		WeightKg:          0.15,
+		Dimensions:        Dimensions{LengthCm: 13, WidthCm: 8, HeightCm: 5},
+		Version:           1,
 	},
 	{
 		ID:          "p3",
+		SKU:         "ST-MON-01",
 		Name:        "Monitor Stand",
 		Description: "Adjustable monitor stand for better ergonomics",
 		Price:       79.99,
 		ImageURL:    "https://example.com/stand.jpg",
 		Stock:       30,
+		
+		LowStockThreshold: 5,
+		WeightKg:          2.5,
+		Dimensions:        Dimensions{LengthCm: 60, WidthCm: 25, HeightCm: 12},
+		Version:           1,
 	},
 }
 
+// productRoutes lists the routes Handler serves, for CORS preflights and metrics
+var productRoutes = routeTable{
+	"/products":                            {"GET", "POST"},
+	"/products/low-stock":                  {"GET"},
+	"/products/{id}":                       {"GET", "PUT", "PATCH", "DELETE"},
+	"/products/{id}/inventory":             {"GET", "POST"},
+	"/products/{id}/notify":                {"POST"},
+	"/products/{id}/images":                {"GET", "POST", "PUT"},
+	"/products/{id}/images/{id}":           {"GET", "PUT", "DELETE"},
+	"/products/{id}/images/{id}/file":      {"GET", "HEAD"},
+	"/products/{id}/images/{id}/thumbnail": {"GET", "HEAD"},
+	"/products/{id}/prices":                {"GET", "POST"},
+	"/products/{id}/prices/{id}":           {"DELETE"},
+	"/products/{id}/reviews":               {"GET", "POST"},
+	"/products/{id}/reviews/{id}":          {"PUT", "DELETE"},
+	"/products/{id}/reviews/{id}/flag":     {"POST"},
+}
+
 // Handler processes product-related requests
 func Handler(w http.ResponseWriter, r *http.Request) {
-	// Set CORS headers
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+	// Tag the request with an ID and log it when done
+	w, r, done, ok := beginRequest(w, r, productRoutes)
+	defer done()
 	
-	// Handle preflight requests
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
+	// Refuse to serve with an invalid configuration
+	if !ok {
+		return
+	}
+	
+	// Add CORS headers and answer preflight requests
+	if handleCORS(w, r, productRoutes) {
+		return
+	}
+
+	// Turn away clients over the rate limit
+	if rateLimited(w, r) {
 		return
 	}
 
@@ -65,21 +140,63 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Path
 	pathParts := strings.Split(path, "/")
 	
+	// Handle low-stock events endpoint
+	if len(pathParts) > 2 && pathParts[2] == "low-stock" {
+		getLowStockEvents(w, r)
+		return
+	}
+	
 	// Handle different endpoints
 	if len(pathParts) > 2 && pathParts[2] != "" {
 		productID := pathParts[2]
+		
+		// Handle inventory ledger endpoint
+		if len(pathParts) > 3 && pathParts[3] == "inventory" {
+			handleInventory(w, r, productID)
+			return
+		}
+		
+		// Handle back-in-stock subscription endpoint
+		if len(pathParts) > 3 && pathParts[3] == "notify" {
+			handleStockSubscription(w, r, productID)
+			return
+		}
+		
+		// Handle image endpoints
+		if len(pathParts) > 3 && pathParts[3] == "images" {
+			handleProductImages(w, r, productID, pathParts)
+			return
+		}
+		
+		// Handle scheduled price endpoints
+		if len(pathParts) > 3 && pathParts[3] == "prices" {
+			handlePriceRules(w, r, productID, pathParts)
+			return
+		}
+		
+		// Handle reviews endpoint
+		if len(pathParts) > 3 && pathParts[3] == "reviews" {
+			handleReviews(w, r, productID, pathParts)
+			return
+		}
+		
 		handleSingleProduct(w, r, productID)
 		return
 	}
 	
-	// List all products
+	// List products, leaving out archived ones unless asked for
 	if r.Method == "GET" {
-		json.NewEncoder(w).Encode(products)
+		r, span := startSpan(r, "getProducts")
+		defer span.End()
+		includeArchived := r.URL.Query().Get("includeArchived") == "true"
+		json.NewEncoder(w).Encode(priceProducts(listProducts(r.Context(), includeArchived)))
 		return
 	}
 	
 	// Create a new product
 	if r.Method == "POST" {
+		r, span := startSpan(r, "createProduct")
+		defer span.End()
 		var newProduct Product
 		err := json.NewDecoder(r.Body).Decode(&newProduct)
 		if err != nil {
@@ -87,14 +204,39 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		
+		// SKUs identify products in bulk imports, so they must be unique
+		if newProduct.SKU != "" && skuTaken(newProduct.SKU, "") {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{"error": "SKU already in use"})
+			return
+		}
+		
 		// Generate a simple ID (in production, use UUID)
-		newProduct.ID = "p" + string(len(products)+1)
+		newProduct.ID = nextProductID()
+		
+		// Stock is derived from the inventory ledger
+		initialStock := newProduct.Stock
+		newProduct.Stock = 0
+		
+		// Ratings come from reviews and images are uploaded separately
+		newProduct.RatingAverage = 0
+		newProduct.RatingCount = 0
+		newProduct.Images = nil
+		newProduct.Version = 1
+		newProduct.ArchivedAt = nil
 		
 		// Add to products
 		products = append(products, newProduct)
 		
+		// Record opening stock
+		if initialStock > 0 {
+			entry := recordInventory(r.Context(), newProduct.ID, InventoryRestock, initialStock, "Opening stock", "")
+			newProduct.Stock = entry.Balance
+		}
+		
+		w.Header().Set("ETag", productETag(newProduct))
 		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(newProduct)
+		json.NewEncoder(w).Encode(priceProduct(newProduct, time.Now()))
 		return
 	}
 	
@@ -104,14 +246,11 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 
 // handleSingleProduct handles requests for a specific product
 func handleSingleProduct(w http.ResponseWriter, r *http.Request, id string) {
+	r, span := startSpan(r, "handleSingleProduct", attribute.String("product.id", id))
+	defer span.End()
+	
 	// Find product by ID
-	var product *Product
-	for i := range products {
-		if products[i].ID == id {
-			product = &products[i]
-			break
-		}
-	}
+	product := findProduct(r.Context(), id)
 	
 	// Product not found
 	if product == nil {
@@ -122,11 +261,20 @@ func handleSingleProduct(w http.ResponseWriter, r *http.Request, id string) {
 	
 	// GET - Return product details
 	if r.Method == "GET" {
-		json.NewEncoder(w).Encode(product)
+		w.Header().Set("ETag", productETag(*product))
+		json.NewEncoder(w).Encode(priceProduct(*product, time.Now()))
 		return
 	}
 	
-	// PUT - Update product
+	// Writes sent with If-Match must be based on the current version
+	if (r.Method == "PUT" || r.Method == "PATCH" || r.Method == "DELETE") && !ifMatch(r, *product) {
+		w.Header().Set("ETag", productETag(*product))
+		w.WriteHeader(http.StatusPreconditionFailed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Product has been modified since it was read"})
+		return
+	}
+	
+	// PUT - Replace product
 	if r.Method == "PUT" {
 		var updatedProduct Product
 		err := json.NewDecoder(r.Body).Decode(&updatedProduct)
@@ -135,36 +283,77 @@ func handleSingleProduct(w http.ResponseWriter, r *http.Request, id string) {
 			return
 		}
 		
-		// Preserve ID
-		updatedProduct.ID = product.ID
+		saveProduct(w, product, updatedProduct)
+		return
+	}
+	
+	// PATCH - Update only the fields sent, as a JSON Merge Patch
+	if r.Method == "PATCH" {
+		if !isMergePatch(r) {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			json.NewEncoder(w).Encode(map[string]string{"error": "PATCH body must be application/merge-patch+json"})
+			return
+		}
 		
-		// Update product
-		for i := range products {
-			if products[i].ID == id {
-				products[i] = updatedProduct
-				break
-			}
+		updatedProduct, err := patchProduct(*product, r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid patch: " + err.Error()})
+			return
 		}
 		
-		json.NewEncoder(w).Encode(updatedProduct)
+		saveProduct(w, product, updatedProduct)
 		return
 	}
 	
-	// DELETE - Remove product
+	// DELETE - Archive product; admins can restore or purge it later
 	if r.Method == "DELETE" {
-		var newProducts []Product
-		for i := range products {
-			if products[i].ID != id {
-				newProducts = append(newProducts, products[i])
-			}
+		if product.ArchivedAt == nil {
+			archiveProduct(product)
 		}
-		products = newProducts
 		
 		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(map[string]string{"message": "Product deleted"})
+		json.NewEncoder(w).Encode(map[string]string{"message": "Product archived"})
 		return
 	}
 	
 	// Method not allowed
 	w.WriteHeader(http.StatusMethodNotAllowed)
 }
+
+// saveProduct stores an edited product for PUT and PATCH, keeping the
+// fields clients can't set and bumping the version
+func saveProduct(w http.ResponseWriter, product *Product, updatedProduct Product) {
+	// Preserve ID, ratings, uploaded images and archive state
+	updatedProduct.ID = product.ID
+	updatedProduct.ArchivedAt = product.ArchivedAt
+	updatedProduct.RatingAverage = product.RatingAverage
+	updatedProduct.RatingCount = product.RatingCount
+	updatedProduct.Images = product.Images
+	syncImages(&updatedProduct)
+	
+	// Stock only changes through the inventory endpoint, so an edit based
+	// on an old read can't undo the sales made since
+	updatedProduct.Stock = product.Stock
+	
+	problems := validateProduct(updatedProduct)
+	if len(problems) > 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": strings.Join(problems, "; ")})
+		return
+	}
+	
+	if updatedProduct.SKU != "" && skuTaken(updatedProduct.SKU, product.ID) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "SKU already in use"})
+		return
+	}
+	
+	updatedProduct.Version = product.Version + 1
+	
+	// Update product
+	*product = updatedProduct
+	
+	w.Header().Set("ETag", productETag(updatedProduct))
+	json.NewEncoder(w).Encode(priceProduct(updatedProduct, time.Now()))
+}
@@ -0,0 +1,460 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Promotion types
+const (
+	PromoPercentOff   = "percent_off"
+	PromoFixedAmount  = "fixed_amount"
+	PromoBuyXGetY     = "buy_x_get_y"
+	PromoFreeShipping = "free_shipping"
+)
+
+// Promotion represents a discount rule redeemable with a coupon code
+type Promotion struct {
+	ID           string     `json:"id"`
+	Code         string     `json:"code"`
+	Description  string     `json:"description"`
+	Type         string     `json:"type"`
+	Value        float64    `json:"value,omitempty"`       // Percent for percent_off, amount for fixed_amount
+	ProductID    string     `json:"productId,omitempty"`   // Restricts buy_x_get_y to one product
+	BuyQuantity  int        `json:"buyQuantity,omitempty"` // buy_x_get_y: units to pay for...
+	GetQuantity  int        `json:"getQuantity,omitempty"` // ...and units given free
+	MinSubtotal  float64    `json:"minSubtotal,omitempty"`
+	StartsAt     *time.Time `json:"startsAt,omitempty"`
+	EndsAt       *time.Time `json:"endsAt,omitempty"`
+	UsageLimit   int        `json:"usageLimit,omitempty"`   // Total redemptions allowed (0 = unlimited)
+	PerUserLimit int        `json:"perUserLimit,omitempty"` // Redemptions per user (0 = unlimited)
+	Active       bool       `json:"active"`
+}
+
+// PromotionListing is a promotion as shoppers see it. It leaves out the
+// coupon code and usage limits, which only staff may see.
+type PromotionListing struct {
+	ID          string     `json:"id"`
+	Description string     `json:"description"`
+	Type        string     `json:"type"`
+	Value       float64    `json:"value,omitempty"`
+	ProductID   string     `json:"productId,omitempty"`
+	BuyQuantity int        `json:"buyQuantity,omitempty"`
+	GetQuantity int        `json:"getQuantity,omitempty"`
+	MinSubtotal float64    `json:"minSubtotal,omitempty"`
+	StartsAt    *time.Time `json:"startsAt,omitempty"`
+	EndsAt      *time.Time `json:"endsAt,omitempty"`
+}
+
+// PromotionUsage records a redemption of a promotion on an order
+type PromotionUsage struct {
+	PromotionID string    `json:"promotionId"`
+	UserID      string    `json:"userId"`
+	OrderID     string    `json:"orderId"`
+	UsedAt      time.Time `json:"usedAt"`
+}
+
+// DiscountLine represents a discount itemized on a cart or order
+type DiscountLine struct {
+	Code        string  `json:"code"`
+	Description string  `json:"description"`
+	ProductID   string  `json:"productId,omitempty"`
+	Amount      float64 `json:"amount"`
+}
+
+// CouponRequest represents a request to apply a coupon to a cart
+type CouponRequest struct {
+	Code string `json:"code"`
+}
+
+// CartSummary is a cart with its prices and discounts worked out
+type CartSummary struct {
+	Cart
+	Lines        []OrderItem    `json:"lines"`
+	Subtotal     float64        `json:"subtotal"`
+	Discounts    []DiscountLine `json:"discounts"`
+	FreeShipping bool           `json:"freeShipping"`
+	Total        float64        `json:"total"`
+}
+
+// In-memory promotion database for demo purposes
+var promotions = []Promotion{
+	{
+		ID:           "promo1",
+		Code:         "WELCOME10",
+		Description:  "10% off your first order over $50",
+		Type:         PromoPercentOff,
+		Value:        10,
+		MinSubtotal:  50,
+		PerUserLimit: 1,
+		Active:       true,
+	},
+	{
+		ID:          "promo2",
+		Code:        "SAVE20",
+		Description: "$20 off orders over $150",
+		Type:        PromoFixedAmount,
+		Value:       20,
+		MinSubtotal: 150,
+		UsageLimit:  100,
+		Active:      true,
+	},
+	{
+		ID:          "promo3",
+		Code:        "MOUSE3FOR2",
+		Description: "Buy 2 wireless mice, get 1 free",
+		Type:        PromoBuyXGetY,
+		ProductID:   "p2",
+		BuyQuantity: 2,
+		GetQuantity: 1,
+		Active:      true,
+	},
+	{
+		ID:          "promo4",
+		Code:        "FREESHIP",
+		Description: "Free shipping on orders over $100",
+		Type:        PromoFreeShipping,
+		MinSubtotal: 100,
+		Active:      true,
+	},
+}
+
+// In-memory redemption log for demo purposes
+var promotionUsages = []PromotionUsage{}
+
+// roundCents rounds an amount to whole cents
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// findPromotion looks up a promotion by its code, ignoring case
+func findPromotion(code string) *Promotion {
+	for i := range promotions {
+		if strings.EqualFold(promotions[i].Code, code) {
+			return &promotions[i]
+		}
+	}
+	return nil
+}
+
+// publicPromotions lists the promotions running now, without their codes
+func publicPromotions(now time.Time) []PromotionListing {
+	listings := []PromotionListing{}
+	for _, promo := range promotions {
+		if !promo.Active {
+			continue
+		}
+		if promo.StartsAt != nil && now.Before(*promo.StartsAt) {
+			continue
+		}
+		if promo.EndsAt != nil && !now.Before(*promo.EndsAt) {
+			continue
+		}
+		listings = append(listings, PromotionListing{
+			ID:          promo.ID,
+			Description: promo.Description,
+			Type:        promo.Type,
+			Value:       promo.Value,
+			ProductID:   promo.ProductID,
+			BuyQuantity: promo.BuyQuantity,
+			GetQuantity: promo.GetQuantity,
+			MinSubtotal: promo.MinSubtotal,
+			StartsAt:    promo.StartsAt,
+			EndsAt:      promo.EndsAt,
+		})
+	}
+	return listings
+}
+
+// cartLines prices the items in a cart at current product prices,
+// including any sale in progress
+func cartLines(cart Cart) []OrderItem {
+	lines := []OrderItem{}
+	now := time.Now()
+	for _, item := range cart.Items {
+		for _, product := range products {
+			if product.ID == item.ProductID {
+				lines = append(lines, OrderItem{
+					ProductID: product.ID,
+					Name:      product.Name,
+					Price:     effectivePrice(product, now),
+					Quantity:  item.Quantity,
+				})
+				break
+			}
+		}
+	}
+	return lines
+}
+
+// linesSubtotal sums the price of order lines
+func linesSubtotal(lines []OrderItem) float64 {
+	var subtotal float64
+	for _, line := range lines {
+		subtotal += line.Price * float64(line.Quantity)
+	}
+	return roundCents(subtotal)
+}
+
+// checkPromotion reports why a promotion can't be redeemed by a user right now
+func checkPromotion(promo *Promotion, userID string, subtotal float64, now time.Time) error {
+	if !promo.Active {
+		return errors.New("Coupon is not active")
+	}
+	if promo.StartsAt != nil && now.Before(*promo.StartsAt) {
+		return errors.New("Coupon is not valid yet")
+	}
+	if promo.EndsAt != nil && !now.Before(*promo.EndsAt) {
+		return errors.New("Coupon has expired")
+	}
+	if subtotal < promo.MinSubtotal {
+		return errors.New("Subtotal must be at least $" + strconv.FormatFloat(promo.MinSubtotal, 'f', 2, 64))
+	}
+
+	// Count previous redemptions
+	var total, byUser int
+	for _, usage := range promotionUsages {
+		if usage.PromotionID != promo.ID {
+			continue
+		}
+		total++
+		if usage.UserID == userID {
+			byUser++
+		}
+	}
+	if promo.UsageLimit > 0 && total >= promo.UsageLimit {
+		return errors.New("Coupon usage limit reached")
+	}
+	if promo.PerUserLimit > 0 && byUser >= promo.PerUserLimit {
+		return errors.New("Coupon already used")
+	}
+
+	return nil
+}
+
+// applyPromotion works out the discount lines a promotion gives on a set of
+// order lines. The returned bool reports whether shipping is free.
+func applyPromotion(promo *Promotion, lines []OrderItem) ([]DiscountLine, bool) {
+	subtotal := linesSubtotal(lines)
+	discounts := []DiscountLine{}
+
+	switch promo.Type {
+	case PromoPercentOff:
+		discounts = append(discounts, DiscountLine{
+			Code:        promo.Code,
+			Description: promo.Description,
+			Amount:      roundCents(subtotal * promo.Value / 100),
+		})
+	case PromoFixedAmount:
+		discounts = append(discounts, DiscountLine{
+			Code:        promo.Code,
+			Description: promo.Description,
+			Amount:      roundCents(math.Min(promo.Value, subtotal)),
+		})
+	case PromoBuyXGetY:
+		groupSize := promo.BuyQuantity + promo.GetQuantity
+		if promo.GetQuantity <= 0 || groupSize <= 0 {
+			break
+		}
+		for _, line := range lines {
+			if promo.ProductID != "" && line.ProductID != promo.ProductID {
+				continue
+			}
+			free := line.Quantity / groupSize * promo.GetQuantity
+			if free == 0 {
+				continue
+			}
+			discounts = append(discounts, DiscountLine{
+				Code:        promo.Code,
+				Description: promo.Description,
+				ProductID:   line.ProductID,
+				Amount:      roundCents(line.Price * float64(free)),
+			})
+		}
+	case PromoFreeShipping:
+		return discounts, true
+	}
+
+	return discounts, false
+}
+
+// summarizeCart prices a cart and applies its coupon, if any
+func summarizeCart(cart Cart) (CartSummary, error) {
+	summary := CartSummary{
+		Cart:      cart,
+		Lines:     cartLines(cart),
+		Discounts: []DiscountLine{},
+	}
+	summary.Subtotal = linesSubtotal(summary.Lines)
+	summary.Total = summary.Subtotal
+
+	if cart.CouponCode == "" {
+		return summary, nil
+	}
+
+	promo := findPromotion(cart.CouponCode)
+	if promo == nil {
+		return summary, errors.New("Coupon not found")
+	}
+	err := checkPromotion(promo, cart.UserID, summary.Subtotal, time.Now())
+	if err != nil {
+		return summary, err
+	}
+
+	summary.Discounts, summary.FreeShipping = applyPromotion(promo, summary.Lines)
+	for _, discount := range summary.Discounts {
+		summary.Total -= discount.Amount
+	}
+	summary.Total = roundCents(math.Max(summary.Total, 0))
+
+	return summary, nil
+}
+
+// handleCoupon applies or removes a coupon on a user's cart
+func handleCoupon(w http.ResponseWriter, r *http.Request, userID string) {
+	r, span := startSpan(r, "handleCoupon", attribute.String("user.id", userID))
+	defer span.End()
+
+	// Find cart by userID
+	cart := findCart(r.Context(), userID)
+
+	// Cart not found
+	if cart == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Cart not found"})
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		var req CouponRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		// Validate against the cart as it is now
+		candidate := *cart
+		candidate.CouponCode = strings.TrimSpace(req.Code)
+		summary, err := summarizeCart(candidate)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		cart.CouponCode = findPromotion(candidate.CouponCode).Code
+		summary.CouponCode = cart.CouponCode
+		json.NewEncoder(w).Encode(summary)
+	case "DELETE":
+		cart.CouponCode = ""
+		summary, _ := summarizeCart(*cart)
+		json.NewEncoder(w).Encode(summary)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// promotionRoutes lists the routes Handler serves, for CORS preflights and metrics
+var promotionRoutes = routeTable{
+	"/promotions": {"GET", "POST"},
+}
+
+// Handler processes promotion-related requests
+func Handler(w http.ResponseWriter, r *http.Request) {
+	// Tag the request with an ID and log it when done
+	w, r, done, ok := beginRequest(w, r, promotionRoutes)
+	defer done()
+
+	// Refuse to serve with an invalid configuration
+	if !ok {
+		return
+	}
+
+	// Add CORS headers and answer preflight requests
+	if handleCORS(w, r, promotionRoutes) {
+		return
+	}
+
+	// Turn away clients over the rate limit
+	if rateLimited(w, r) {
+		return
+	}
+
+	// Set content type
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case "GET":
+		// Codes are for staff, who list them under /admin/promotions
+		_, span := startSpan(r, "listPromotions")
+		defer span.End()
+		json.NewEncoder(w).Encode(publicPromotions(time.Now()))
+	case "POST":
+		createPromotion(w, r)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// createPromotion adds a new promotion rule
+func createPromotion(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "createPromotion")
+	defer span.End()
+
+	// Only staff may create coupon codes
+	if requireStaff(w, r) {
+		return
+	}
+
+	var promo Promotion
+	err := json.NewDecoder(r.Body).Decode(&promo)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate rule
+	promo.Code = strings.TrimSpace(promo.Code)
+	var invalid string
+	switch {
+	case promo.Code == "":
+		invalid = "Code required"
+	case findPromotion(promo.Code) != nil:
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Code already in use"})
+		return
+	case promo.Type == PromoPercentOff && (promo.Value <= 0 || promo.Value > 100):
+		invalid = "Percent must be between 0 and 100"
+	case promo.Type == PromoFixedAmount && promo.Value <= 0:
+		invalid = "Amount must be positive"
+	case promo.Type == PromoBuyXGetY && (promo.BuyQuantity <= 0 || promo.GetQuantity <= 0):
+		invalid = "Buy and get quantities must be positive"
+	case promo.Type != PromoPercentOff && promo.Type != PromoFixedAmount &&
+		promo.Type != PromoBuyXGetY && promo.Type != PromoFreeShipping:
+		invalid = "Unknown promotion type"
+	case promo.StartsAt != nil && promo.EndsAt != nil && !promo.EndsAt.After(*promo.StartsAt):
+		invalid = "End time must be after start time"
+	}
+	if invalid != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": invalid})
+		return
+	}
+
+	// Generate a simple ID (in production, use UUID)
+	promo.ID = "promo" + strconv.Itoa(len(promotions)+1)
+
+	promotions = append(promotions, promo)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(promo)
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPublicPromotionsHideCodes(t *testing.T) {
+	keepSlice(t, &promotions)
+	now := time.Unix(1700000000, 0)
+	later := now.Add(time.Hour)
+	earlier := now.Add(-time.Hour)
+	promotions = []Promotion{
+		{ID: "promo1", Code: "RUNNING", Type: PromoFreeShipping, UsageLimit: 5, Active: true},
+		{ID: "promo2", Code: "INACTIVE", Type: PromoFreeShipping},
+		{ID: "promo3", Code: "UPCOMING", Type: PromoFreeShipping, StartsAt: &later, Active: true},
+		{ID: "promo4", Code: "EXPIRED", Type: PromoFreeShipping, EndsAt: &earlier, Active: true},
+	}
+
+	listings := publicPromotions(now)
+	if len(listings) != 1 || listings[0].ID != "promo1" {
+		t.Fatalf("listed %+v, want only the running promotion", listings)
+	}
+
+	body, _ := json.Marshal(listings)
+	for _, secret := range []string{"RUNNING", "code", "usageLimit"} {
+		if strings.Contains(string(body), secret) {
+			t.Errorf("public listing %s contains %q", body, secret)
+		}
+	}
+}
+
+func TestCreatePromotionIsForStaff(t *testing.T) {
+	keepSlice(t, &promotions)
+	body := `{"code":"FREE","type":"percent_off","value":100,"active":true}`
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"anonymous", "", 401},
+		{"shopper", issueToken("u1", time.Now()), 403},
+		{"staff", issueToken("u2", time.Now()), 201},
+	}
+	for _, tc := range tests {
+		r := httptest.NewRequest("POST", "/promotions", strings.NewReader(body))
+		if tc.token != "" {
+			r.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+		w := httptest.NewRecorder()
+		createPromotion(w, r)
+		if w.Code != tc.want {
+			t.Errorf("%s: status %d, want %d", tc.name, w.Code, tc.want)
+		}
+	}
+	if findPromotion("FREE") == nil || len(promotions) != 5 {
+		t.Error("staff promotion not created exactly once")
+	}
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"encoding/json"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"learn_go/ratelimit"
+)
+
+// rateLimiter is built from the configuration on first use. It stays nil
+// when rate limiting is turned off.
+var (
+	rateLimiter     *ratelimit.Limiter
+	rateLimiterErr  error
+	rateLimiterOnce sync.Once
+)
+
+func getRateLimiter() (*ratelimit.Limiter, error) {
+	rateLimiterOnce.Do(func() {
+		cfg, err := getConfig()
+		if err != nil {
+			rateLimiterErr = err
+			return
+		}
+		if cfg.RateLimit.Enabled {
+			rateLimiter = ratelimit.New(cfg.RateLimit.RequestsPerMinute, cfg.RateLimit.Burst)
+		}
+	})
+	return rateLimiter, rateLimiterErr
+}
+
+// clientAddress identifies the client a request counts against
+func clientAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// rateLimited turns away a client that is over the configured rate with
+// 429 Too Many Requests. Without a valid configuration it can't tell, so
+// it answers 503 Service Unavailable rather than let everyone through. It
+// returns true when the request has been answered and the Handler should
+// stop.
+func rateLimited(w http.ResponseWriter, r *http.Request) bool {
+	limiter, err := getRateLimiter()
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Service unavailable"})
+		return true
+	}
+	if limiter == nil {
+		return false
+	}
+	allowed, wait := limiter.Allow(clientAddress(r))
+	if allowed {
+		return false
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(map[string]string{"error": "Too many requests"})
+	return true
+}
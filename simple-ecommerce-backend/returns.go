@@ -0,0 +1,527 @@
+package handler
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Return statuses
+const (
+	ReturnRequested = "requested"
+	ReturnApproved  = "approved"
+	ReturnRejected  = "rejected"
+	ReturnReceived  = "received"
+	ReturnRefunded  = "refunded"
+)
+
+// ReturnItem represents a quantity of an order item being sent back
+type ReturnItem struct {
+	ProductID        string  `json:"productId"`
+	Name             string  `json:"name,omitempty"`
+	Quantity         int     `json:"quantity"`
+	ApprovedQuantity int     `json:"approvedQuantity"`
+	UnitRefund       float64 `json:"unitRefund,omitempty"` // What the customer paid per unit
+}
+
+// ReturnEvent records a status change on a return
+type ReturnEvent struct {
+	Status    string    `json:"status"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Return represents a return merchandise authorization (RMA)
+type Return struct {
+	ID           string        `json:"id"`
+	OrderID      string        `json:"orderId"`
+	UserID       string        `json:"userId"`
+	Items        []ReturnItem  `json:"items"`
+	Reason       string        `json:"reason"`
+	Status       string        `json:"status"`
+	RefundAmount float64       `json:"refundAmount"`
+	RefundID     string        `json:"refundId,omitempty"`
+	History      []ReturnEvent `json:"history"`
+	CreatedAt    time.Time     `json:"createdAt"`
+	UpdatedAt    time.Time     `json:"updatedAt"`
+}
+
+// Refund represents money paid back to a customer
+type Refund struct {
+	ID        string    `json:"id"`
+	ReturnID  string    `json:"returnId"`
+	OrderID   string    `json:"orderId"`
+	UserID    string    `json:"userId"`
+	Amount    float64   `json:"amount"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ReturnCreateRequest represents a customer's request to return items
+type ReturnCreateRequest struct {
+	Items  []ReturnItem `json:"items"`
+	Reason string       `json:"reason"`
+}
+
+// ReturnReviewRequest represents a staff decision on a return. For
+// approvals, Items may limit which lines and quantities are accepted.
+type ReturnReviewRequest struct {
+	Items []ReturnItem `json:"items"`
+	Note  string       `json:"note"`
+}
+
+// In-memory return and refund databases for demo purposes
+var returns = []Return{}
+var refunds = []Refund{}
+
+// returnableStatuses are the order statuses that accept new returns
+var returnableStatuses = []string{"delivered", "partially_refunded"}
+
+// paidLineTotals works out what the customer paid for each line of an
+// order, by product: the line total after its share of discounts, plus its
+// share of the tax charged. The tax is shared out in proportion to the tax
+// each line attracts, so tax-exempt lines get none back.
+func paidLineTotals(order Order) map[string]float64 {
+	lines := taxableLines(order.Items, order.Discounts)
+
+	lineTax := make([]float64, len(lines))
+	var totalTax, goods float64
+	calculator, err := getTaxCalculator()
+	for i, line := range lines {
+		goods += line.Amount
+		if err != nil {
+			continue
+		}
+		taxes, _ := calculator.Calculate(order.ShippingAddr, []TaxableLine{line})
+		for _, tax := range taxes {
+			lineTax[i] += tax.Amount
+		}
+		totalTax += lineTax[i]
+	}
+
+	paid := map[string]float64{}
+	for i, line := range lines {
+		amount := line.Amount
+		if totalTax > 0 {
+			amount += order.TaxTotal * lineTax[i] / totalTax
+		} else if goods > 0 {
+			// The rates have changed since; fall back to spreading by value
+			amount += order.TaxTotal * line.Amount / goods
+		}
+		paid[line.ProductID] += amount
+	}
+	return paid
+}
+
+// refundableAmount is what is left to refund on an order. Shipping is not
+// refunded.
+func refundableAmount(order Order) float64 {
+	return math.Max(roundCents(order.TotalAmount-order.ShippingCost-order.RefundedAmount), 0)
+}
+
+// returnedQuantity counts units of a product already on open or completed returns
+func returnedQuantity(orderID, productID string) int {
+	var quantity int
+	for _, ret := range returns {
+		if ret.OrderID != orderID || ret.Status == ReturnRejected {
+			continue
+		}
+		for _, item := range ret.Items {
+			if item.ProductID != productID {
+				continue
+			}
+			if ret.Status == ReturnRequested {
+				quantity += item.Quantity
+			} else {
+				quantity += item.ApprovedQuantity
+			}
+		}
+	}
+	return quantity
+}
+
+// addReturnEvent moves a return to a new status and records it in the history
+func addReturnEvent(ret *Return, status, note string) {
+	now := time.Now()
+	ret.Status = status
+	ret.UpdatedAt = now
+	ret.History = append(ret.History, ReturnEvent{Status: status, Note: note, CreatedAt: now})
+}
+
+// handleOrderReturns lists or opens returns for a customer's order
+func handleOrderReturns(w http.ResponseWriter, r *http.Request, userID, orderID string) {
+	r, span := startSpan(r, "handleOrderReturns", attribute.String("order.id", orderID))
+	defer span.End()
+
+	// Find order by ID
+	order := findOrder(r.Context(), orderID)
+
+	// Order not found
+	if order == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Order not found"})
+		return
+	}
+
+	// Verify order belongs to user
+	if order.UserID != userID {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Access denied"})
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		orderReturns := []Return{}
+		for _, ret := range returns {
+			if ret.OrderID == orderID {
+				orderReturns = append(orderReturns, ret)
+			}
+		}
+		json.NewEncoder(w).Encode(orderReturns)
+	case "POST":
+		createReturn(w, r, order)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// createReturn opens a return request for items on an order
+func createReturn(w http.ResponseWriter, r *http.Request, order *Order) {
+	var req ReturnCreateRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Only delivered orders can be returned
+	returnable := false
+	for _, status := range returnableStatuses {
+		if order.Status == status {
+			returnable = true
+			break
+		}
+	}
+	if !returnable {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Only delivered orders can be returned"})
+		return
+	}
+
+	if len(req.Items) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "At least one item required"})
+		return
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Reason required"})
+		return
+	}
+
+	// Validate each line against what was ordered and not yet returned
+	paid := paidLineTotals(*order)
+	items := []ReturnItem{}
+	requested := map[string]int{}
+	for _, reqItem := range req.Items {
+		var orderItem *OrderItem
+		for i := range order.Items {
+			if order.Items[i].ProductID == reqItem.ProductID {
+				orderItem = &order.Items[i]
+				break
+			}
+		}
+		if orderItem == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Product not in order: " + reqItem.ProductID})
+			return
+		}
+
+		available := orderItem.Quantity - returnedQuantity(order.ID, orderItem.ProductID) - requested[orderItem.ProductID]
+		if reqItem.Quantity <= 0 || reqItem.Quantity > available {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Can return between 1 and " + strconv.Itoa(available) + " of " + orderItem.Name,
+			})
+			return
+		}
+
+		requested[orderItem.ProductID] += reqItem.Quantity
+		items = append(items, ReturnItem{
+			ProductID:  orderItem.ProductID,
+			Name:       orderItem.Name,
+			Quantity:   reqItem.Quantity,
+			UnitRefund: roundCents(paid[orderItem.ProductID] / float64(orderItem.Quantity)),
+		})
+	}
+
+	now := time.Now()
+	ret := Return{
+		ID:        "rma" + strconv.Itoa(len(returns)+1),
+		OrderID:   order.ID,
+		UserID:    order.UserID,
+		Items:     items,
+		Reason:    req.Reason,
+		History:   []ReturnEvent{},
+		CreatedAt: now,
+	}
+	addReturnEvent(&ret, ReturnRequested, req.Reason)
+	returns = append(returns, ret)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(ret)
+}
+
+// returnRoutes lists the routes Handler serves, for CORS preflights and metrics
+var returnRoutes = routeTable{
+	"/returns":              {"GET"},
+	"/returns/{id}":         {"GET"},
+	"/returns/{id}/approve": {"POST"},
+	"/returns/{id}/reject":  {"POST"},
+	"/returns/{id}/receive": {"POST"},
+	"/returns/{id}/refund":  {"POST"},
+}
+
+// Handler processes staff requests for returns
+func Handler(w http.ResponseWriter, r *http.Request) {
+	// Tag the request with an ID and log it when done
+	w, r, done, ok := beginRequest(w, r, returnRoutes)
+	defer done()
+
+	// Refuse to serve with an invalid configuration
+	if !ok {
+		return
+	}
+
+	// Add CORS headers and answer preflight requests
+	if handleCORS(w, r, returnRoutes) {
+		return
+	}
+
+	// Turn away clients over the rate limit
+	if rateLimited(w, r) {
+		return
+	}
+
+	// Set content type
+	w.Header().Set("Content-Type", "application/json")
+
+	// Extract path parts
+	path := r.URL.Path
+	pathParts := strings.Split(path, "/")
+
+	// List all returns
+	if len(pathParts) < 3 || pathParts[2] == "" {
+		if r.Method != "GET" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		_, span := startSpan(r, "listReturns")
+		defer span.End()
+		status := r.URL.Query().Get("status")
+		list := []Return{}
+		for _, ret := range returns {
+			if status == "" || ret.Status == status {
+				list = append(list, ret)
+			}
+		}
+		json.NewEncoder(w).Encode(list)
+		return
+	}
+
+	// Find return by ID
+	returnID := pathParts[2]
+	r, span := startSpan(r, "handleReturn", attribute.String("return.id", returnID))
+	defer span.End()
+	ret := findReturn(r.Context(), returnID)
+
+	// Return not found
+	if ret == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Return not found"})
+		return
+	}
+
+	// GET - Return details
+	if len(pathParts) < 4 || pathParts[3] == "" {
+		if r.Method != "GET" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		json.NewEncoder(w).Encode(ret)
+		return
+	}
+
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ReturnReviewRequest
+	if r.ContentLength != 0 {
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Handle workflow actions
+	switch pathParts[3] {
+	case "approve":
+		approveReturn(w, r, ret, req)
+	case "reject":
+		rejectReturn(w, ret, req)
+	case "receive":
+		receiveReturn(w, r, ret, req)
+	case "refund":
+		refundReturn(w, r, ret, req)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Unknown action"})
+	}
+}
+
+// approveReturn accepts some or all of the requested lines
+func approveReturn(w http.ResponseWriter, r *http.Request, ret *Return, req ReturnReviewRequest) {
+	if ret.Status != ReturnRequested {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Return is " + ret.Status})
+		return
+	}
+
+	// Approve everything unless specific lines were given
+	approved := map[string]int{}
+	for _, item := range ret.Items {
+		approved[item.ProductID] = item.Quantity
+	}
+	if len(req.Items) > 0 {
+		approved = map[string]int{}
+		for _, reqItem := range req.Items {
+			approved[reqItem.ProductID] = reqItem.Quantity
+		}
+	}
+
+	// Validate approved quantities
+	var total int
+	for _, item := range ret.Items {
+		quantity := approved[item.ProductID]
+		if quantity < 0 || quantity > item.Quantity {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid approved quantity for " + item.Name})
+			return
+		}
+		total += quantity
+	}
+	if total == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Nothing approved, reject the return instead"})
+		return
+	}
+
+	// Refund each line's share of what was paid for it, working from line
+	// totals so rounding per unit can't add up to more than was paid
+	order := findOrder(r.Context(), ret.OrderID)
+	paid := paidLineTotals(*order)
+	quantities := map[string]int{}
+	for _, item := range order.Items {
+		quantities[item.ProductID] += item.Quantity
+	}
+	ret.RefundAmount = 0
+	for i := range ret.Items {
+		item := &ret.Items[i]
+		item.ApprovedQuantity = approved[item.ProductID]
+		ret.RefundAmount += roundCents(paid[item.ProductID] * float64(item.ApprovedQuantity) / float64(quantities[item.ProductID]))
+	}
+	ret.RefundAmount = math.Min(roundCents(ret.RefundAmount), refundableAmount(*order))
+	addReturnEvent(ret, ReturnApproved, req.Note)
+
+	json.NewEncoder(w).Encode(ret)
+}
+
+// rejectReturn declines a return request
+func rejectReturn(w http.ResponseWriter, ret *Return, req ReturnReviewRequest) {
+	if ret.Status != ReturnRequested {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Return is " + ret.Status})
+		return
+	}
+
+	addReturnEvent(ret, ReturnRejected, req.Note)
+
+	json.NewEncoder(w).Encode(ret)
+}
+
+// receiveReturn marks the goods as back in the warehouse and restocks them
+func receiveReturn(w http.ResponseWriter, r *http.Request, ret *Return, req ReturnReviewRequest) {
+	if ret.Status != ReturnApproved {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Return is " + ret.Status})
+		return
+	}
+
+	for _, item := range ret.Items {
+		if item.ApprovedQuantity > 0 {
+			recordInventory(r.Context(), item.ProductID, InventoryReturn, item.ApprovedQuantity, ret.Reason, ret.ID)
+		}
+	}
+	addReturnEvent(ret, ReturnReceived, req.Note)
+
+	json.NewEncoder(w).Encode(ret)
+}
+
+// refundReturn pays back the approved lines and updates the order status
+func refundReturn(w http.ResponseWriter, r *http.Request, ret *Return, req ReturnReviewRequest) {
+	if ret.Status != ReturnReceived {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Return is " + ret.Status})
+		return
+	}
+
+	// Never refund more than is left on the order, whatever other returns
+	// have paid back since this one was approved
+	order := findOrder(r.Context(), ret.OrderID)
+	ret.RefundAmount = math.Min(ret.RefundAmount, refundableAmount(*order))
+
+	refund := Refund{
+		ID:        "rf" + strconv.Itoa(len(refunds)+1),
+		ReturnID:  ret.ID,
+		OrderID:   ret.OrderID,
+		UserID:    ret.UserID,
+		Amount:    ret.RefundAmount,
+		CreatedAt: time.Now(),
+	}
+	refunds = append(refunds, refund)
+	ret.RefundID = refund.ID
+	addReturnEvent(ret, ReturnRefunded, req.Note)
+
+	// Update order status
+	order.RefundedAmount = roundCents(order.RefundedAmount + refund.Amount)
+
+	// Fully refunded once every unit has come back
+	order.Status = "refunded"
+	for _, item := range order.Items {
+		var refunded int
+		for _, other := range returns {
+			if other.OrderID != ret.OrderID || other.Status != ReturnRefunded {
+				continue
+			}
+			for _, returned := range other.Items {
+				if returned.ProductID == item.ProductID {
+					refunded += returned.ApprovedQuantity
+				}
+			}
+		}
+		if refunded < item.Quantity {
+			order.Status = "partially_refunded"
+			break
+		}
+	}
+
+	json.NewEncoder(w).Encode(ret)
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// requestReturn opens a return for an order as its customer
+func requestReturn(t *testing.T, order Order, items ...ReturnItem) *Return {
+	t.Helper()
+	body, _ := json.Marshal(ReturnCreateRequest{Items: items, Reason: "Changed my mind"})
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/orders/"+order.UserID+"/"+order.ID+"/returns", strings.NewReader(string(body)))
+	handleOrderReturns(w, r, order.UserID, order.ID)
+	if w.Code != 201 {
+		t.Fatalf("request return: status %d: %s", w.Code, w.Body)
+	}
+
+	var ret Return
+	json.NewDecoder(w.Body).Decode(&ret)
+	return findReturn(t.Context(), ret.ID)
+}
+
+// settleReturn approves, receives and refunds a return in full
+func settleReturn(t *testing.T, ret *Return) {
+	t.Helper()
+	steps := []struct {
+		name string
+		step func(w *httptest.ResponseRecorder)
+	}{
+		{"approve", func(w *httptest.ResponseRecorder) {
+			approveReturn(w, httptest.NewRequest("POST", "/returns/"+ret.ID+"/approve", nil), ret, ReturnReviewRequest{})
+		}},
+		{"receive", func(w *httptest.ResponseRecorder) {
+			receiveReturn(w, httptest.NewRequest("POST", "/returns/"+ret.ID+"/receive", nil), ret, ReturnReviewRequest{})
+		}},
+		{"refund", func(w *httptest.ResponseRecorder) {
+			refundReturn(w, httptest.NewRequest("POST", "/returns/"+ret.ID+"/refund", nil), ret, ReturnReviewRequest{})
+		}},
+	}
+	for _, s := range steps {
+		w := httptest.NewRecorder()
+		s.step(w)
+		if w.Code != 200 {
+			t.Fatalf("%s %s: status %d: %s", s.name, ret.ID, w.Code, w.Body)
+		}
+	}
+}
+
+func TestReturnFlowRefundsWhatWasPaid(t *testing.T) {
+	keepStore(t)
+	for i := range products {
+		if products[i].ID == "p2" {
+			products[i].TaxCategory = TaxExempt
+		}
+	}
+
+	placed := placeOrder(t, "u1", CartItem{ProductID: "p1", Quantity: 1}, CartItem{ProductID: "p2", Quantity: 2})
+	order := findOrder(t.Context(), placed.ID)
+	order.Status = "delivered"
+	p2Stock := stockFor("p2")
+
+	// The exempt line gets back what it cost, and none of the tax
+	ret := requestReturn(t, *order, ReturnItem{ProductID: "p2", Quantity: 2})
+	if ret.Status != ReturnRequested {
+		t.Fatalf("new return is %s", ret.Status)
+	}
+	settleReturn(t, ret)
+	p2Paid := order.Items[1].Price * 2
+	if ret.Status != ReturnRefunded || ret.RefundAmount != roundCents(p2Paid) {
+		t.Errorf("exempt return %s refunded %v, want %v", ret.Status, ret.RefundAmount, roundCents(p2Paid))
+	}
+	if stockFor("p2") != p2Stock+2 {
+		t.Errorf("p2 stock %d after receiving, want %d", stockFor("p2"), p2Stock+2)
+	}
+	if order.Status != "partially_refunded" {
+		t.Errorf("order is %s after a partial return", order.Status)
+	}
+
+	// The taxed line gets back its price and all of the tax
+	ret = requestReturn(t, *order, ReturnItem{ProductID: "p1", Quantity: 1})
+	settleReturn(t, ret)
+	if want := roundCents(order.Items[0].Price + order.TaxTotal); ret.RefundAmount != want {
+		t.Errorf("taxed return refunded %v, want %v", ret.RefundAmount, want)
+	}
+	if order.Status != "refunded" || order.RefundedAmount != roundCents(order.TotalAmount-order.ShippingCost) {
+		t.Errorf("order %s with %v refunded, want everything but shipping", order.Status, order.RefundedAmount)
+	}
+	if len(refunds) != 2 {
+		t.Errorf("%d refunds recorded, want 2", len(refunds))
+	}
+}
+
+func TestReturnRefundsNeverExceedThePayment(t *testing.T) {
+	keepStore(t)
+
+	// Three units at 1.00 less a 1.00 discount: 2.00 paid, 0.666... each
+	orders = append(orders, Order{
+		ID:          "o9",
+		UserID:      "u1",
+		Items:       []OrderItem{{ProductID: "p9", Name: "Sticker", Price: 1, Quantity: 3}},
+		Subtotal:    3,
+		Discounts:   []DiscountLine{{Code: "SAVE1", Amount: 1}},
+		TotalAmount: 2,
+		Status:      "delivered",
+	})
+	order := findOrder(t.Context(), "o9")
+
+	var refunded float64
+	for _, want := range []float64{0.67, 0.67, 0.66} {
+		ret := requestReturn(t, *order, ReturnItem{ProductID: "p9", Quantity: 1})
+		settleReturn(t, ret)
+		if ret.RefundAmount != want {
+			t.Errorf("%s refunded %v, want %v", ret.ID, ret.RefundAmount, want)
+		}
+		refunded += ret.RefundAmount
+	}
+	if roundCents(refunded) != 2 || order.RefundedAmount != 2 {
+		t.Errorf("refunded %v in total, order records %v; want 2", refunded, order.RefundedAmount)
+	}
+}
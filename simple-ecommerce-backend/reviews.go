@@ -0,0 +1,426 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Review statuses
+const (
+	ReviewPublished = "published"
+	ReviewHidden    = "hidden"
+)
+
+// Review represents a customer's rating and review of a product
+type Review struct {
+	ID         string    `json:"id"`
+	ProductID  string    `json:"productId"`
+	UserID     string    `json:"userId"`
+	AuthorName string    `json:"authorName"`
+	OrderID    string    `json:"orderId"` // The delivered order that verifies the purchase
+	Rating     int       `json:"rating"`
+	Title      string    `json:"title,omitempty"`
+	Body       string    `json:"body"`
+	Status     string    `json:"status"`
+	Flags      int       `json:"flags"`
+	FlaggedBy  []string  `json:"-"` // Users who flagged it, each counted once
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// ReviewRequest represents a request to post or edit a review
+type ReviewRequest struct {
+	UserID string `json:"userId"`
+	Rating int    `json:"rating"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+}
+
+// ReviewPage is a page of reviews for a product
+type ReviewPage struct {
+	ProductID     string   `json:"productId"`
+	RatingAverage float64  `json:"ratingAverage"`
+	RatingCount   int      `json:"ratingCount"`
+	Reviews       []Review `json:"reviews"`
+	Total         int      `json:"total"`
+	Page          int      `json:"page"`
+	PageSize      int      `json:"pageSize"`
+}
+
+// In-memory review database for demo purposes
+var reviews = []Review{}
+
+// nextReviewID counts every review ever posted so deleted IDs aren't reused
+var nextReviewID = 1
+
+// reviewableStatuses are order statuses where the customer has the goods
+var reviewableStatuses = []string{"delivered", "partially_refunded"}
+
+// verifiedPurchase returns a delivered order of the user's containing the product
+func verifiedPurchase(userID, productID string) *Order {
+	for i := range orders {
+		if orders[i].UserID != userID {
+			continue
+		}
+		delivered := false
+		for _, status := range reviewableStatuses {
+			if orders[i].Status == status {
+				delivered = true
+				break
+			}
+		}
+		if !delivered {
+			continue
+		}
+		for _, item := range orders[i].Items {
+			if item.ProductID == productID {
+				return &orders[i]
+			}
+		}
+	}
+	return nil
+}
+
+// refreshRating recalculates a product's cached rating from its published reviews
+func refreshRating(ctx context.Context, productID string) {
+	var sum, count int
+	for _, review := range reviews {
+		if review.ProductID == productID && review.Status == ReviewPublished {
+			sum += review.Rating
+			count++
+		}
+	}
+
+	product := findProduct(ctx, productID)
+	if product != nil {
+		product.RatingCount = count
+		product.RatingAverage = 0
+		if count > 0 {
+			product.RatingAverage = math.Round(float64(sum)/float64(count)*10) / 10
+		}
+	}
+}
+
+// validateReview checks the rating and text of a review
+func validateReview(req ReviewRequest) string {
+	if req.Rating < 1 || req.Rating > 5 {
+		return "Rating must be between 1 and 5"
+	}
+	if strings.TrimSpace(req.Body) == "" {
+		return "Review text required"
+	}
+	if len(req.Body) > 5000 {
+		return "Review text is too long"
+	}
+	return ""
+}
+
+// handleReviews processes review requests for a product
+func handleReviews(w http.ResponseWriter, r *http.Request, productID string, pathParts []string) {
+	r, span := startSpan(r, "handleReviews", attribute.String("product.id", productID))
+	defer span.End()
+
+	// Find product by ID
+	product := findProduct(r.Context(), productID)
+
+	// Product not found
+	if product == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Product not found"})
+		return
+	}
+
+	// Handle specific review
+	if len(pathParts) > 4 && pathParts[4] != "" {
+		handleSingleReview(w, r, productID, pathParts)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		listReviews(w, r, *product)
+	case "POST":
+		createReview(w, r, productID)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// listReviews returns a page of a product's published reviews, newest first
+func listReviews(w http.ResponseWriter, r *http.Request, product Product) {
+	published := []Review{}
+	for i := len(reviews) - 1; i >= 0; i-- {
+		if reviews[i].ProductID == product.ID && reviews[i].Status == ReviewPublished {
+			published = append(published, reviews[i])
+		}
+	}
+
+	page, pageSize, start, end := pageBounds(r.URL.Query(), len(published))
+
+	json.NewEncoder(w).Encode(ReviewPage{
+		ProductID:     product.ID,
+		RatingAverage: product.RatingAverage,
+		RatingCount:   product.RatingCount,
+		Reviews:       published[start:end],
+		Total:         len(published),
+		Page:          page,
+		PageSize:      pageSize,
+	})
+}
+
+// createReview posts a review from a customer who received the product
+func createReview(w http.ResponseWriter, r *http.Request, productID string) {
+	var req ReviewRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Find user by ID
+	user := findUser(r.Context(), req.UserID)
+
+	// User not found
+	if user == nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "User not found"})
+		return
+	}
+
+	if invalid := validateReview(req); invalid != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": invalid})
+		return
+	}
+
+	// Only verified purchases can be reviewed
+	order := verifiedPurchase(user.ID, productID)
+	if order == nil {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Only customers who received this product can review it"})
+		return
+	}
+
+	// One review per customer per product
+	for _, review := range reviews {
+		if review.ProductID == productID && review.UserID == user.ID {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{"error": "You have already reviewed this product"})
+			return
+		}
+	}
+
+	now := time.Now()
+	review := Review{
+		ID:         "r" + strconv.Itoa(nextReviewID),
+		ProductID:  productID,
+		UserID:     user.ID,
+		AuthorName: user.Name,
+		OrderID:    order.ID,
+		Rating:     req.Rating,
+		Title:      strings.TrimSpace(req.Title),
+		Body:       strings.TrimSpace(req.Body),
+		Status:     ReviewPublished,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	nextReviewID++
+	reviews = append(reviews, review)
+	refreshRating(r.Context(), productID)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(review)
+}
+
+// handleSingleReview lets authors edit or delete their review, and signed-in
+// users flag it
+func handleSingleReview(w http.ResponseWriter, r *http.Request, productID string, pathParts []string) {
+	reviewID := pathParts[4]
+
+	// Find review by ID
+	var review *Review
+	for i := range reviews {
+		if reviews[i].ID == reviewID && reviews[i].ProductID == productID {
+			review = &reviews[i]
+			break
+		}
+	}
+
+	// Review not found
+	if review == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Review not found"})
+		return
+	}
+
+	// Handle flag endpoint
+	if len(pathParts) > 5 && pathParts[5] == "flag" {
+		if r.Method != "POST" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Flags come from signed-in users, and each counts once
+		userID := tokenUserID(r)
+		if userID == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Sign in required"})
+			return
+		}
+		flagged := false
+		for _, flagger := range review.FlaggedBy {
+			if flagger == userID {
+				flagged = true
+				break
+			}
+		}
+		if !flagged {
+			review.FlaggedBy = append(review.FlaggedBy, userID)
+			review.Flags = len(review.FlaggedBy)
+		}
+		json.NewEncoder(w).Encode(map[string]string{"message": "Review flagged for moderation"})
+		return
+	}
+
+	// PUT - Edit own review
+	if r.Method == "PUT" {
+		var req ReviewRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		// Verify review belongs to user
+		if req.UserID != review.UserID {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Access denied"})
+			return
+		}
+
+		if invalid := validateReview(req); invalid != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": invalid})
+			return
+		}
+
+		review.Rating = req.Rating
+		review.Title = strings.TrimSpace(req.Title)
+		review.Body = strings.TrimSpace(req.Body)
+		review.UpdatedAt = time.Now()
+		refreshRating(r.Context(), productID)
+
+		json.NewEncoder(w).Encode(review)
+		return
+	}
+
+	// DELETE - Remove own review
+	if r.Method == "DELETE" {
+		// Verify review belongs to user
+		if r.URL.Query().Get("userId") != review.UserID {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Access denied"})
+			return
+		}
+
+		var newReviews []Review
+		for i := range reviews {
+			if reviews[i].ID != reviewID {
+				newReviews = append(newReviews, reviews[i])
+			}
+		}
+		reviews = newReviews
+		refreshRating(r.Context(), productID)
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Review deleted"})
+		return
+	}
+
+	// Method not allowed
+	w.WriteHeader(http.StatusMethodNotAllowed)
+}
+
+// handleReviewModeration lets staff list reviews and hide or restore them
+func handleReviewModeration(w http.ResponseWriter, r *http.Request, pathParts []string) {
+	r, span := startSpan(r, "handleReviewModeration")
+	defer span.End()
+
+	// List reviews, optionally only flagged ones or by status
+	if len(pathParts) < 4 || pathParts[3] == "" {
+		if r.Method != "GET" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		query := r.URL.Query()
+		status := query.Get("status")
+		flagged := query.Get("flagged") == "true"
+
+		list := []Review{}
+		for _, review := range reviews {
+			if status != "" && review.Status != status {
+				continue
+			}
+			if flagged && review.Flags == 0 {
+				continue
+			}
+			list = append(list, review)
+		}
+
+		page, pageSize, start, end := pageBounds(query, len(list))
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"reviews":  list[start:end],
+			"total":    len(list),
+			"page":     page,
+			"pageSize": pageSize,
+		})
+		return
+	}
+
+	// Find review by ID
+	var review *Review
+	for i := range reviews {
+		if reviews[i].ID == pathParts[3] {
+			review = &reviews[i]
+			break
+		}
+	}
+
+	// Review not found
+	if review == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Review not found"})
+		return
+	}
+
+	if len(pathParts) < 5 || r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Handle moderation actions
+	switch pathParts[4] {
+	case "hide":
+		review.Status = ReviewHidden
+	case "publish":
+		review.Status = ReviewPublished
+		review.Flags = 0
+		review.FlaggedBy = nil
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Unknown action"})
+		return
+	}
+	review.UpdatedAt = time.Now()
+	refreshRating(r.Context(), review.ProductID)
+
+	json.NewEncoder(w).Encode(review)
+}
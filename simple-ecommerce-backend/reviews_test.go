@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEachUserFlagsAReviewOnce(t *testing.T) {
+	keepSlice(t, &reviews)
+	reviews = []Review{{ID: "rv1", ProductID: "p1", UserID: "u1", Status: ReviewPublished}}
+
+	flag := func(token string) int {
+		r := httptest.NewRequest("POST", "/products/p1/reviews/rv1/flag", nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handleSingleReview(w, r, "p1", strings.Split(r.URL.Path, "/"))
+		return w.Code
+	}
+
+	if code := flag(""); code != 401 {
+		t.Errorf("anonymous flag: status %d, want 401", code)
+	}
+	for _, userID := range []string{"u1", "u1", "u1", "u2"} {
+		if code := flag(issueToken(userID, time.Now())); code != 200 {
+			t.Errorf("flag by %s: status %d, want 200", userID, code)
+		}
+	}
+	if reviews[0].Flags != 2 {
+		t.Errorf("review has %d flags, want one per user", reviews[0].Flags)
+	}
+}
@@ -0,0 +1,45 @@
+package handler
+
+import "strings"
+
+// routeTable lists the paths a Handler serves and the methods each accepts.
+// Preflights are answered from it and requests are measured by its
+// patterns. A {id} segment matches any value.
+//
+// Like the Handlers, matching ignores the first segment, which is whatever
+// the deployment mounts the function under (/carts and /cart both reach
+// cart.go); it is written in the table only to make it readable.
+type routeTable map[string][]string
+
+// match returns the route pattern that serves path and its methods, or
+// nil methods if no route does. A trailing slash is ignored, and literal
+// segments win over {id}, so /products/low-stock isn't taken for a product
+// ID.
+func (routes routeTable) match(path string) (string, []string) {
+	parts := strings.Split(strings.TrimSuffix(path, "/"), "/")
+	var route string
+	var methods []string
+	fewestIDs := -1
+	for pattern, patternMethods := range routes {
+		patternParts := strings.Split(pattern, "/")
+		if len(patternParts) != len(parts) {
+			continue
+		}
+		ids := 0
+		for i, part := range patternParts {
+			if i < 2 {
+				continue
+			}
+			if part == "{id}" && parts[i] != "" {
+				ids++
+			} else if part != parts[i] {
+				ids = -1
+				break
+			}
+		}
+		if ids >= 0 && (fewestIDs < 0 || ids < fewestIDs) {
+			route, methods, fewestIDs = pattern, patternMethods, ids
+		}
+	}
+	return route, methods
+}
@@ -0,0 +1,381 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Shipment statuses
+const (
+	ShipmentShipped   = "shipped"
+	ShipmentDelivered = "delivered"
+)
+
+// fulfillmentStatuses are the order statuses derived from shipments.
+// Orders in any other status (e.g. refunded) are left alone.
+var fulfillmentStatuses = []string{"pending", "processing", "partially_shipped", "shipped", "delivered"}
+
+// shippableStatuses are the order statuses that accept new shipments.
+// Refunded or cancelled orders must not go out, and shipping them would make
+// them returnable again.
+var shippableStatuses = []string{"pending", "processing", "partially_shipped"}
+
+// ShipmentItem represents a quantity of an order item in a parcel
+type ShipmentItem struct {
+	ProductID string `json:"productId"`
+	Quantity  int    `json:"quantity"`
+}
+
+// Shipment represents a parcel sent for part or all of an order
+type Shipment struct {
+	ID             string         `json:"id"`
+	OrderID        string         `json:"orderId"`
+	Items          []ShipmentItem `json:"items"`
+	Carrier        string         `json:"carrier"`
+	TrackingNumber string         `json:"trackingNumber"`
+	Status         string         `json:"status"`
+	CreatedAt      time.Time      `json:"createdAt"`
+	ShippedAt      *time.Time     `json:"shippedAt,omitempty"`
+	DeliveredAt    *time.Time     `json:"deliveredAt,omitempty"`
+}
+
+// ShipmentRequest represents a request to create a shipment
+type ShipmentRequest struct {
+	OrderID        string         `json:"orderId"`
+	Items          []ShipmentItem `json:"items"`
+	Carrier        string         `json:"carrier"`
+	TrackingNumber string         `json:"trackingNumber"`
+}
+
+// In-memory shipment database for demo purposes
+var shipments = []Shipment{
+	{
+		ID:             "sh1",
+		OrderID:        "o2",
+		Items:          []ShipmentItem{{ProductID: "p2", Quantity: 1}},
+		Carrier:        "UPS",
+		TrackingNumber: "1Z999AA10123456784",
+		Status:         ShipmentDelivered,
+		CreatedAt:      time.Now().Add(-9 * 24 * time.Hour),
+		ShippedAt:      timePtr(time.Now().Add(-9 * 24 * time.Hour)),
+		DeliveredAt:    timePtr(time.Now().Add(-7 * 24 * time.Hour)),
+	},
+}
+
+// timePtr returns a pointer to a time, for optional timestamps
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+// shippedQuantity counts units of a product already in shipments for an order
+func shippedQuantity(orderID, productID string) int {
+	var quantity int
+	for _, shipment := range shipments {
+		if shipment.OrderID != orderID {
+			continue
+		}
+		for _, item := range shipment.Items {
+			if item.ProductID == productID {
+				quantity += item.Quantity
+			}
+		}
+	}
+	return quantity
+}
+
+// updateFulfillmentStatus derives an order's status from its shipments
+func updateFulfillmentStatus(order *Order) {
+	derived := false
+	for _, status := range fulfillmentStatuses {
+		if order.Status == status {
+			derived = true
+			break
+		}
+	}
+	if !derived {
+		return
+	}
+
+	hasShipments, allDelivered := false, true
+	for _, shipment := range shipments {
+		if shipment.OrderID == order.ID {
+			hasShipments = true
+			if shipment.Status != ShipmentDelivered {
+				allDelivered = false
+			}
+		}
+	}
+
+	// Nothing shipped yet
+	if !hasShipments {
+		return
+	}
+
+	for _, item := range order.Items {
+		if shippedQuantity(order.ID, item.ProductID) < item.Quantity {
+			order.Status = "partially_shipped"
+			return
+		}
+	}
+
+	if allDelivered {
+		order.Status = "delivered"
+	} else {
+		order.Status = "shipped"
+	}
+}
+
+// getOrderShipments lists the shipments for a customer's order
+func getOrderShipments(w http.ResponseWriter, r *http.Request, userID, orderID string) {
+	r, span := startSpan(r, "getOrderShipments", attribute.String("order.id", orderID))
+	defer span.End()
+
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Find order by ID
+	order := findOrder(r.Context(), orderID)
+
+	// Order not found
+	if order == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Order not found"})
+		return
+	}
+
+	// Verify order belongs to user
+	if order.UserID != userID {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Access denied"})
+		return
+	}
+
+	orderShipments := []Shipment{}
+	for _, shipment := range shipments {
+		if shipment.OrderID == orderID {
+			orderShipments = append(orderShipments, shipment)
+		}
+	}
+
+	json.NewEncoder(w).Encode(orderShipments)
+}
+
+// shipmentRoutes lists the routes Handler serves, for CORS preflights and metrics
+var shipmentRoutes = routeTable{
+	"/shipments":              {"GET", "POST"},
+	"/shipments/{id}":         {"GET"},
+	"/shipments/{id}/deliver": {"POST"},
+}
+
+// Handler processes staff requests for shipments
+func Handler(w http.ResponseWriter, r *http.Request) {
+	// Tag the request with an ID and log it when done
+	w, r, done, ok := beginRequest(w, r, shipmentRoutes)
+	defer done()
+
+	// Refuse to serve with an invalid configuration
+	if !ok {
+		return
+	}
+
+	// Add CORS headers and answer preflight requests
+	if handleCORS(w, r, shipmentRoutes) {
+		return
+	}
+
+	// Turn away clients over the rate limit
+	if rateLimited(w, r) {
+		return
+	}
+
+	// Set content type
+	w.Header().Set("Content-Type", "application/json")
+
+	// Extract path parts
+	path := r.URL.Path
+	pathParts := strings.Split(path, "/")
+
+	// Handle shipment collection
+	if len(pathParts) < 3 || pathParts[2] == "" {
+		switch r.Method {
+		case "GET":
+			_, span := startSpan(r, "listShipments")
+			defer span.End()
+			orderID := r.URL.Query().Get("orderId")
+			list := []Shipment{}
+			for _, shipment := range shipments {
+				if orderID == "" || shipment.OrderID == orderID {
+					list = append(list, shipment)
+				}
+			}
+			json.NewEncoder(w).Encode(list)
+		case "POST":
+			createShipment(w, r)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	// Find shipment by ID
+	shipmentID := pathParts[2]
+	r, span := startSpan(r, "handleShipment", attribute.String("shipment.id", shipmentID))
+	defer span.End()
+	shipment := findShipment(r.Context(), shipmentID)
+
+	// Shipment not found
+	if shipment == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Shipment not found"})
+		return
+	}
+
+	// Handle delivery confirmation
+	if len(pathParts) > 3 && pathParts[3] == "deliver" {
+		if r.Method != "POST" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		deliverShipment(w, r, shipment)
+		return
+	}
+
+	// GET - Return shipment details
+	if r.Method == "GET" {
+		json.NewEncoder(w).Encode(shipment)
+		return
+	}
+
+	// Method not allowed
+	w.WriteHeader(http.StatusMethodNotAllowed)
+}
+
+// createShipment records a parcel leaving the warehouse for some order items
+func createShipment(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "createShipment")
+	defer span.End()
+
+	var req ShipmentRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Find order by ID
+	order := findOrder(r.Context(), req.OrderID)
+
+	// Order not found
+	if order == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Order not found"})
+		return
+	}
+
+	shippable := false
+	for _, status := range shippableStatuses {
+		if order.Status == status {
+			shippable = true
+			break
+		}
+	}
+	if !shippable {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Order is " + order.Status + " and can't be shipped"})
+		return
+	}
+
+	if req.Carrier == "" || req.TrackingNumber == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Carrier and tracking number required"})
+		return
+	}
+
+	// Ship everything still outstanding unless specific items were given
+	items := req.Items
+	if len(items) == 0 {
+		for _, orderItem := range order.Items {
+			remaining := orderItem.Quantity - shippedQuantity(order.ID, orderItem.ProductID)
+			if remaining > 0 {
+				items = append(items, ShipmentItem{ProductID: orderItem.ProductID, Quantity: remaining})
+			}
+		}
+		if len(items) == 0 {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Order is already fully shipped"})
+			return
+		}
+	}
+
+	// Validate items against what is left to ship
+	requested := map[string]int{}
+	for _, item := range items {
+		var orderItem *OrderItem
+		for i := range order.Items {
+			if order.Items[i].ProductID == item.ProductID {
+				orderItem = &order.Items[i]
+				break
+			}
+		}
+		if orderItem == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Product not in order: " + item.ProductID})
+			return
+		}
+
+		remaining := orderItem.Quantity - shippedQuantity(order.ID, item.ProductID) - requested[item.ProductID]
+		if item.Quantity <= 0 || item.Quantity > remaining {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Can ship between 1 and " + strconv.Itoa(remaining) + " of " + orderItem.Name,
+			})
+			return
+		}
+		requested[item.ProductID] += item.Quantity
+	}
+
+	now := time.Now()
+	shipment := Shipment{
+		ID:             "sh" + strconv.Itoa(len(shipments)+1),
+		OrderID:        order.ID,
+		Items:          items,
+		Carrier:        req.Carrier,
+		TrackingNumber: req.TrackingNumber,
+		Status:         ShipmentShipped,
+		CreatedAt:      now,
+		ShippedAt:      &now,
+	}
+	shipments = append(shipments, shipment)
+
+	updateFulfillmentStatus(order)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(shipment)
+}
+
+// deliverShipment marks a shipment as delivered
+func deliverShipment(w http.ResponseWriter, r *http.Request, shipment *Shipment) {
+	if shipment.Status == ShipmentDelivered {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Shipment already delivered"})
+		return
+	}
+
+	now := time.Now()
+	shipment.Status = ShipmentDelivered
+	shipment.DeliveredAt = &now
+
+	// Update order status
+	if order := findOrder(r.Context(), shipment.OrderID); order != nil {
+		updateFulfillmentStatus(order)
+	}
+
+	json.NewEncoder(w).Encode(shipment)
+}
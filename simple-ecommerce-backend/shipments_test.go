@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// ship posts a shipment for an order and returns the response status
+func ship(orderID, items string) int {
+	body := `{"orderId":"` + orderID + `","carrier":"UPS","trackingNumber":"1Z999","items":` + items + `}`
+	w := httptest.NewRecorder()
+	createShipment(w, httptest.NewRequest("POST", "/shipments", strings.NewReader(body)))
+	return w.Code
+}
+
+func TestShipmentsDriveFulfillment(t *testing.T) {
+	keepStore(t)
+	placed := placeOrder(t, "u1", CartItem{ProductID: "p1", Quantity: 2})
+	order := findOrder(t.Context(), placed.ID)
+
+	if code := ship(order.ID, `[{"productId":"p1","quantity":1}]`); code != 201 || order.Status != "partially_shipped" {
+		t.Fatalf("first parcel: status %d, order %s", code, order.Status)
+	}
+	if code := ship(order.ID, `[]`); code != 201 || order.Status != "shipped" {
+		t.Fatalf("rest of the order: status %d, order %s", code, order.Status)
+	}
+	if code := ship(order.ID, `[]`); code != 409 {
+		t.Errorf("shipping a fully shipped order: status %d, want 409", code)
+	}
+
+	for i := range shipments {
+		if shipments[i].OrderID == order.ID {
+			deliverShipment(httptest.NewRecorder(), httptest.NewRequest("POST", "/shipments/"+shipments[i].ID+"/deliver", nil), &shipments[i])
+		}
+	}
+	if order.Status != "delivered" {
+		t.Errorf("order is %s once every parcel arrived", order.Status)
+	}
+}
+
+func TestClosedOrdersAreNotShipped(t *testing.T) {
+	for _, status := range []string{"refunded", "partially_refunded", "cancelled", "delivered"} {
+		keepStore(t)
+		placed := placeOrder(t, "u1", CartItem{ProductID: "p1", Quantity: 1})
+		order := findOrder(t.Context(), placed.ID)
+		order.Status = status
+
+		if code := ship(order.ID, `[]`); code != 409 {
+			t.Errorf("%s order: status %d, want 409", status, code)
+		}
+		if order.Status != status {
+			t.Errorf("%s order moved to %s", status, order.Status)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package handler
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Shipping method types
+const (
+	ShippingFlatRate    = "flat_rate"
+	ShippingWeightBased = "weight_based"
+	ShippingFreeOver    = "free_over"
+	ShippingPerZone     = "per_zone"
+)
+
+// volumetricDivisor converts cubic centimetres to billable kilograms
+const volumetricDivisor = 5000
+
+// Dimensions represents the packed size of a product in centimetres
+type Dimensions struct {
+	LengthCm float64 `json:"lengthCm"`
+	WidthCm  float64 `json:"widthCm"`
+	HeightCm float64 `json:"heightCm"`
+}
+
+// ShippingZone is a per-zone rate for a group of countries.
+// A zone with no countries matches any country.
+type ShippingZone struct {
+	Name      string   `json:"name"`
+	Countries []string `json:"countries,omitempty"`
+	Rate      float64  `json:"rate"`
+	PerKg     float64  `json:"perKg,omitempty"`
+}
+
+// ShippingMethod represents a way of delivering an order
+type ShippingMethod struct {
+	ID                string         `json:"id"`
+	Name              string         `json:"name"`
+	Type              string         `json:"type"`
+	Rate              float64        `json:"rate,omitempty"`     // Flat price, or base price for weight_based
+	PerKg             float64        `json:"perKg,omitempty"`    // weight_based: price per billable kg
+	FreeOver          float64        `json:"freeOver,omitempty"` // free_over: free from this subtotal
+	Zones             []ShippingZone `json:"zones,omitempty"`    // per_zone: rates by country
+	Countries         []string       `json:"countries,omitempty"`
+	ExcludedCountries []string       `json:"excludedCountries,omitempty"`
+	MaxWeightKg       float64        `json:"maxWeightKg,omitempty"`
+	EstimatedDays     string         `json:"estimatedDays,omitempty"`
+}
+
+// ShippingQuote is the price of a shipping method for a particular cart
+type ShippingQuote struct {
+	MethodID      string  `json:"methodId"`
+	Name          string  `json:"name"`
+	Cost          float64 `json:"cost"`
+	EstimatedDays string  `json:"estimatedDays,omitempty"`
+}
+
+// ShippingQuoteResponse is what we return when quoting a cart
+type ShippingQuoteResponse struct {
+	UserID       string          `json:"userId"`
+	WeightKg     float64         `json:"weightKg"` // Billable weight
+	FreeShipping bool            `json:"freeShipping"`
+	Quotes       []ShippingQuote `json:"quotes"`
+}
+
+// shippingConfig is the layout of the shipping methods file
+type shippingConfig struct {
+	Methods []ShippingMethod `json:"methods"`
+}
+
+// defaultShippingMethods is used when SHIPPING_METHODS_FILE is not set
+//
+//go:embed shipping_methods.json
+var defaultShippingMethods []byte
+
+var (
+	shippingMethods     []ShippingMethod
+	shippingMethodsErr  error
+	shippingMethodsOnce sync.Once
+)
+
+// getShippingMethods returns the configured shipping methods, loading them on first use
+func getShippingMethods() ([]ShippingMethod, error) {
+	shippingMethodsOnce.Do(func() {
+		data := defaultShippingMethods
+		if path := os.Getenv("SHIPPING_METHODS_FILE"); path != "" {
+			data, shippingMethodsErr = os.ReadFile(path)
+			if shippingMethodsErr != nil {
+				return
+			}
+		}
+		var config shippingConfig
+		shippingMethodsErr = json.Unmarshal(data, &config)
+		shippingMethods = config.Methods
+	})
+	return shippingMethods, shippingMethodsErr
+}
+
+// containsCountry reports whether a country is in a list, by name or code
+func containsCountry(countries []string, country string) bool {
+	for _, c := range countries {
+		if sameCountry(c, country) {
+			return true
+		}
+	}
+	return false
+}
+
+// billableWeight is the greater of actual and volumetric weight of the lines
+func billableWeight(lines []OrderItem) float64 {
+	var weight float64
+	for _, line := range lines {
+		for _, product := range products {
+			if product.ID != line.ProductID {
+				continue
+			}
+			dims := product.Dimensions
+			volumetric := dims.LengthCm * dims.WidthCm * dims.HeightCm / volumetricDivisor
+			weight += math.Max(product.WeightKg, volumetric) * float64(line.Quantity)
+			break
+		}
+	}
+	return math.Round(weight*1000) / 1000
+}
+
+// quote prices a method for a parcel going to a country. It returns false
+// if the method doesn't serve that country or can't carry the parcel.
+func (m ShippingMethod) quote(country string, weightKg, subtotal float64) (float64, bool) {
+	if len(m.Countries) > 0 && !containsCountry(m.Countries, country) {
+		return 0, false
+	}
+	if containsCountry(m.ExcludedCountries, country) {
+		return 0, false
+	}
+	if m.MaxWeightKg > 0 && weightKg > m.MaxWeightKg {
+		return 0, false
+	}
+
+	switch m.Type {
+	case ShippingFlatRate:
+		return m.Rate, true
+	case ShippingWeightBased:
+		return roundCents(m.Rate + m.PerKg*math.Ceil(weightKg)), true
+	case ShippingFreeOver:
+		if subtotal >= m.FreeOver {
+			return 0, true
+		}
+		return m.Rate, true
+	case ShippingPerZone:
+		for _, zone := range m.Zones {
+			if len(zone.Countries) == 0 || containsCountry(zone.Countries, country) {
+				return roundCents(zone.Rate + zone.PerKg*math.Ceil(weightKg)), true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// quoteShipping returns the methods available for a set of lines, cheapest first
+func quoteShipping(addr Address, lines []OrderItem, subtotal float64, freeShipping bool) ([]ShippingQuote, error) {
+	methods, err := getShippingMethods()
+	if err != nil {
+		return nil, err
+	}
+
+	weight := billableWeight(lines)
+	quotes := []ShippingQuote{}
+	for _, method := range methods {
+		cost, ok := method.quote(addr.Country, weight, subtotal)
+		if !ok {
+			continue
+		}
+		if freeShipping {
+			cost = 0
+		}
+		quotes = append(quotes, ShippingQuote{
+			MethodID:      method.ID,
+			Name:          method.Name,
+			Cost:          cost,
+			EstimatedDays: method.EstimatedDays,
+		})
+	}
+
+	sort.SliceStable(quotes, func(i, j int) bool {
+		return quotes[i].Cost < quotes[j].Cost
+	})
+
+	return quotes, nil
+}
+
+// chooseShipping picks the requested method from the quotes, or the
+// cheapest one if no method was requested
+func chooseShipping(quotes []ShippingQuote, methodID string) (ShippingQuote, error) {
+	if len(quotes) == 0 {
+		return ShippingQuote{}, errors.New("No shipping methods available for this address")
+	}
+	if methodID == "" {
+		return quotes[0], nil
+	}
+	for _, quote := range quotes {
+		if quote.MethodID == methodID {
+			return quote, nil
+		}
+	}
+	return ShippingQuote{}, errors.New("Shipping method not available: " + methodID)
+}
+
+// getShippingQuotes quotes the available shipping methods for a user's cart
+func getShippingQuotes(w http.ResponseWriter, r *http.Request, userID string) {
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Address comes from a saved address or query parameters
+	query := r.URL.Query()
+	addr := Address{
+		City:    query.Get("city"),
+		State:   query.Get("state"),
+		ZipCode: query.Get("zipCode"),
+		Country: query.Get("country"),
+	}
+	if addressID := query.Get("addressId"); addressID != "" {
+		saved := findSavedAddress(userID, addressID)
+		if saved == nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Address not found"})
+			return
+		}
+		addr = saved.Address
+	}
+	if addr.Country == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Country required"})
+		return
+	}
+
+	// Find cart by userID
+	cart := findCart(r.Context(), userID)
+
+	// Cart not found or empty
+	if cart == nil || len(cart.Items) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Cart is empty"})
+		return
+	}
+
+	// Free-over thresholds apply to the discounted total
+	summary, err := summarizeCart(*cart)
+	if err != nil {
+		summary.Total = summary.Subtotal
+	}
+
+	quotes, err := quoteShipping(addr, summary.Lines, summary.Total, summary.FreeShipping)
+	if err != nil {
+		requestLogger(r).Error("shipping configuration unavailable", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Shipping configuration unavailable"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(ShippingQuoteResponse{
+		UserID:       userID,
+		WeightKg:     billableWeight(summary.Lines),
+		FreeShipping: summary.FreeShipping,
+		Quotes:       quotes,
+	})
+}
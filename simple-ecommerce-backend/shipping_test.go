@@ -0,0 +1,88 @@
+package handler
+
+import "testing"
+
+func TestBillableWeightUsesVolumetricWeight(t *testing.T) {
+	keepStore(t)
+	products = []Product{
+		{ID: "anvil", WeightKg: 10, Dimensions: Dimensions{LengthCm: 20, WidthCm: 20, HeightCm: 20}},
+		{ID: "pillow", WeightKg: 0.5, Dimensions: Dimensions{LengthCm: 50, WidthCm: 40, HeightCm: 20}},
+	}
+
+	// The anvil's 1.6 kg volume is lighter than its 10 kg; the pillow's 8 kg
+	// volume outweighs its 0.5 kg
+	weight := billableWeight([]OrderItem{{ProductID: "anvil", Quantity: 1}, {ProductID: "pillow", Quantity: 2}})
+	if weight != 26 {
+		t.Errorf("billable weight %v, want 26", weight)
+	}
+}
+
+func TestShippingMethodQuotes(t *testing.T) {
+	methods, err := getShippingMethods()
+	if err != nil {
+		t.Fatal(err)
+	}
+	byID := map[string]ShippingMethod{}
+	for _, method := range methods {
+		byID[method.ID] = method
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		country  string
+		weightKg float64
+		subtotal float64
+		cost     float64
+		ok       bool
+	}{
+		{"free_over below the threshold", "standard", "US", 1, 74.99, 5.99, true},
+		{"free_over at the threshold", "standard", "USA", 1, 75, 0, true},
+		{"free_over outside its countries", "standard", "Canada", 1, 100, 0, false},
+		{"weight_based rounds up to whole kg", "ground", "Canada", 2.1, 0, 8.25, true},
+		{"weight_based over the limit", "ground", "USA", 30.5, 0, 0, false},
+		{"flat_rate", "express", "United States", 50, 0, 24.99, true},
+		{"per_zone picks the country's zone", "international", "UK", 1.5, 0, 32.99, true},
+		{"per_zone falls back to the catch-all", "international", "Japan", 1, 0, 45.99, true},
+		{"per_zone excluded country", "international", "USA", 1, 0, 0, false},
+	}
+	for _, tc := range tests {
+		cost, ok := byID[tc.method].quote(tc.country, tc.weightKg, tc.subtotal)
+		if cost != tc.cost || ok != tc.ok {
+			t.Errorf("%s: got %v, %v; want %v, %v", tc.name, cost, ok, tc.cost, tc.ok)
+		}
+	}
+}
+
+func TestQuoteShippingCheapestFirst(t *testing.T) {
+	keepStore(t)
+	products = []Product{{ID: "box", WeightKg: 2}}
+	lines := []OrderItem{{ProductID: "box", Quantity: 1}}
+
+	quotes, err := quoteShipping(testAddress, lines, 20, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, quote := range quotes {
+		ids = append(ids, quote.MethodID)
+	}
+	if len(ids) != 3 || ids[0] != "standard" || ids[1] != "ground" || ids[2] != "express" {
+		t.Errorf("quoted %v, want standard, ground, express", ids)
+	}
+
+	// A free shipping promotion zeroes every quote
+	quotes, _ = quoteShipping(testAddress, lines, 20, true)
+	for _, quote := range quotes {
+		if quote.Cost != 0 {
+			t.Errorf("%s costs %v with free shipping", quote.MethodID, quote.Cost)
+		}
+	}
+
+	if chosen, err := chooseShipping(quotes, ""); err != nil || chosen.MethodID != quotes[0].MethodID {
+		t.Errorf("default choice %+v, %v; want the cheapest", chosen, err)
+	}
+	if _, err := chooseShipping(quotes, "international"); err == nil {
+		t.Error("chose a method not offered for the address")
+	}
+}
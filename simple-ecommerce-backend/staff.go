@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// requireStaff turns away requests that aren't from a signed-in staff
+// member: 401 Unauthorized without a valid token and 403 Forbidden for
+// anyone else. It returns true when the request has been answered and the
+// Handler should stop.
+func requireStaff(w http.ResponseWriter, r *http.Request) bool {
+	userID := tokenUserID(r)
+	if userID == "" {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Sign in required"})
+		return true
+	}
+
+	user := findUser(r.Context(), userID)
+	if user == nil || !user.Staff {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Staff only"})
+		return true
+	}
+	return false
+}
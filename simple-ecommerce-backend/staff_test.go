@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRequireStaff(t *testing.T) {
+	tests := []struct {
+		name          string
+		authorization string
+		want          int
+	}{
+		{"no token", "", 401},
+		{"forged token", "Bearer token-u2-1700000000-00", 401},
+		{"expired token", "Bearer " + issueToken("u2", time.Now().Add(-tokenLifetime-time.Hour)), 401},
+		{"shopper", "Bearer " + issueToken("u1", time.Now()), 403},
+		{"unknown user", "Bearer " + issueToken("u404", time.Now()), 403},
+		{"staff", "Bearer " + issueToken("u2", time.Now()), 200},
+	}
+	for _, tc := range tests {
+		r := httptest.NewRequest("GET", "/admin/orders", nil)
+		if tc.authorization != "" {
+			r.Header.Set("Authorization", tc.authorization)
+		}
+		w := httptest.NewRecorder()
+		stopped := requireStaff(w, r)
+		if stopped != (tc.want != 200) || w.Code != tc.want {
+			t.Errorf("%s: stopped %v, status %d; want %d", tc.name, stopped, w.Code, tc.want)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// StockEvent represents a product crossing its low-stock threshold
+type StockEvent struct {
+	ID        string    `json:"id"`
+	ProductID string    `json:"productId"`
+	Name      string    `json:"name"`
+	Stock     int       `json:"stock"`
+	Threshold int       `json:"threshold"`
+	Reference string    `json:"reference,omitempty"` // e.g. the order that caused it
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// StockSubscription represents a shopper waiting for a product to come back in stock
+type StockSubscription struct {
+	ID         string     `json:"id"`
+	ProductID  string     `json:"productId"`
+	UserID     string     `json:"userId,omitempty"`
+	Email      string     `json:"email"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	NotifiedAt *time.Time `json:"notifiedAt,omitempty"`
+}
+
+// SubscriptionRequest represents a "notify me" request
+type SubscriptionRequest struct {
+	UserID string `json:"userId"`
+	Email  string `json:"email"`
+}
+
+// Notification represents a message queued for delivery
+type Notification struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Email     string    `json:"email"`
+	ProductID string    `json:"productId"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// In-memory stores for demo purposes
+var stockEvents = []StockEvent{}
+var stockSubscriptions = []StockSubscription{}
+
+// maxQueuedNotifications bounds the notification queue. When a mailer falls
+// behind, the oldest notifications are dropped to make room.
+const maxQueuedNotifications = 1000
+
+// notificationQueue holds notifications waiting for a mailer to pick them
+// up through the admin notifications endpoints
+var notificationQueue = []Notification{}
+
+// lastNotificationNumber numbers notifications; it only goes up, so IDs stay
+// unique as the queue is drained
+var lastNotificationNumber = 0
+
+// queueNotification adds a notification, dropping the oldest when full
+func queueNotification(notification Notification) {
+	lastNotificationNumber++
+	notification.ID = "n" + strconv.Itoa(lastNotificationNumber)
+	if len(notificationQueue) >= maxQueuedNotifications {
+		notificationQueue = notificationQueue[len(notificationQueue)-maxQueuedNotifications+1:]
+	}
+	notificationQueue = append(notificationQueue, notification)
+}
+
+// checkStockAlerts emits low-stock events and queues back-in-stock
+// notifications when a stock movement crosses the relevant boundary
+func checkStockAlerts(product Product, before, after int, reference string) {
+	// Low-stock threshold crossed on the way down
+	if product.LowStockThreshold > 0 && before > product.LowStockThreshold && after <= product.LowStockThreshold {
+		stockEvents = append(stockEvents, StockEvent{
+			ID:        "se" + strconv.Itoa(len(stockEvents)+1),
+			ProductID: product.ID,
+			Name:      product.Name,
+			Stock:     after,
+			Threshold: product.LowStockThreshold,
+			Reference: reference,
+			CreatedAt: time.Now(),
+		})
+	}
+
+	// Back in stock: only a restock from nothing, not every movement that
+	// leaves stock above zero, and never for an archived product
+	if before <= 0 && after > 0 && product.ArchivedAt == nil {
+		now := time.Now()
+		for i := range stockSubscriptions {
+			sub := &stockSubscriptions[i]
+			if sub.ProductID != product.ID || sub.NotifiedAt != nil {
+				continue
+			}
+			queueNotification(Notification{
+				Type:      "back_in_stock",
+				Email:     sub.Email,
+				ProductID: product.ID,
+				Message:   product.Name + " is back in stock",
+				CreatedAt: now,
+			})
+			sub.NotifiedAt = &now
+		}
+	}
+}
+
+// getLowStockEvents returns low-stock events, optionally filtered by product
+func getLowStockEvents(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "getLowStockEvents")
+	defer span.End()
+
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	productID := r.URL.Query().Get("productId")
+
+	events := []StockEvent{}
+	for _, event := range stockEvents {
+		if productID == "" || event.ProductID == productID {
+			events = append(events, event)
+		}
+	}
+
+	json.NewEncoder(w).Encode(events)
+}
+
+// handleStockSubscription subscribes a shopper to back-in-stock notifications
+func handleStockSubscription(w http.ResponseWriter, r *http.Request, productID string) {
+	r, span := startSpan(r, "handleStockSubscription", attribute.String("product.id", productID))
+	defer span.End()
+
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Find product by ID
+	product := findProduct(r.Context(), productID)
+
+	// Product not found
+	if product == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Product not found"})
+		return
+	}
+
+	// Archived products won't come back into stock
+	if product.ArchivedAt != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Product is no longer available"})
+		return
+	}
+
+	var req SubscriptionRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Fall back to the account email if only a user ID was given
+	if req.Email == "" && req.UserID != "" {
+		if user := findUser(r.Context(), req.UserID); user != nil {
+			req.Email = user.Email
+		}
+	}
+
+	err = validateEmail(req.Email)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Nothing to wait for
+	if product.Stock > 0 {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Product is in stock"})
+		return
+	}
+
+	// Don't subscribe the same email twice
+	for _, sub := range stockSubscriptions {
+		if sub.ProductID == productID && sub.Email == req.Email && sub.NotifiedAt == nil {
+			json.NewEncoder(w).Encode(sub)
+			return
+		}
+	}
+
+	sub := StockSubscription{
+		ID:        "ss" + strconv.Itoa(len(stockSubscriptions)+1),
+		ProductID: productID,
+		UserID:    req.UserID,
+		Email:     req.Email,
+		CreatedAt: time.Now(),
+	}
+	stockSubscriptions = append(stockSubscriptions, sub)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(sub)
+}
+
+// handleNotifications lets a mailer list queued notifications and remove
+// each one once it has been delivered
+func handleNotifications(w http.ResponseWriter, r *http.Request, pathParts []string) {
+	r, span := startSpan(r, "handleNotifications")
+	defer span.End()
+
+	// List queued notifications, oldest first
+	if len(pathParts) < 4 || pathParts[3] == "" {
+		if r.Method != "GET" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		page, pageSize, start, end := pageBounds(r.URL.Query(), len(notificationQueue))
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"notifications": notificationQueue[start:end],
+			"total":         len(notificationQueue),
+			"page":          page,
+			"pageSize":      pageSize,
+		})
+		return
+	}
+
+	if r.Method != "DELETE" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Remove a delivered notification from the queue
+	for i, notification := range notificationQueue {
+		if notification.ID == pathParts[3] {
+			notificationQueue = append(notificationQueue[:i], notificationQueue[i+1:]...)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNotFound)
+	json.NewEncoder(w).Encode(map[string]string{"error": "Notification not found"})
+}
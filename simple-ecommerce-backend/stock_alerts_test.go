@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestBackInStockOnlyFromZero(t *testing.T) {
+	archived := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name     string
+		before   int
+		after    int
+		archived *time.Time
+		notify   bool
+	}{
+		{"restock from zero", 0, 5, nil, true},
+		{"restock from oversold", -2, 1, nil, true},
+		{"restock while in stock", 3, 8, nil, false},
+		{"sale leaving stock", 8, 3, nil, false},
+		{"restock to zero", -2, 0, nil, false},
+		{"archived product", 0, 5, &archived, false},
+	}
+	for _, tc := range tests {
+		stockSubscriptions = []StockSubscription{{ID: "ss1", ProductID: "p1", Email: "a@example.com"}}
+		notificationQueue = []Notification{}
+
+		product := Product{ID: "p1", Name: "Widget", ArchivedAt: tc.archived}
+		checkStockAlerts(product, tc.before, tc.after, "")
+
+		if got := len(notificationQueue) == 1; got != tc.notify {
+			t.Errorf("%s: notified = %v, want %v", tc.name, got, tc.notify)
+		}
+		if got := stockSubscriptions[0].NotifiedAt != nil; got != tc.notify {
+			t.Errorf("%s: subscription marked notified = %v, want %v", tc.name, got, tc.notify)
+		}
+	}
+}
+
+func TestNotificationQueueIsBounded(t *testing.T) {
+	notificationQueue = []Notification{}
+	for i := 0; i < maxQueuedNotifications+10; i++ {
+		queueNotification(Notification{Type: "back_in_stock"})
+	}
+
+	if len(notificationQueue) != maxQueuedNotifications {
+		t.Fatalf("queue holds %d notifications, want %d", len(notificationQueue), maxQueuedNotifications)
+	}
+	first := notificationQueue[0].ID
+	last := notificationQueue[len(notificationQueue)-1].ID
+	if first != "n"+strconv.Itoa(lastNotificationNumber-maxQueuedNotifications+1) || last != "n"+strconv.Itoa(lastNotificationNumber) {
+		t.Errorf("queue runs %s..%s, want the newest notifications", first, last)
+	}
+}
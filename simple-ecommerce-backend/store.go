@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Store lookups and writes used by the handlers. Each runs in its own span
+// so a slow request shows where the time went.
+
+// findProduct looks up a product by ID
+func findProduct(ctx context.Context, productID string) *Product {
+	_, span := startStoreSpan(ctx, "findProduct", attribute.String("product.id", productID))
+	defer span.End()
+
+	for i := range products {
+		if products[i].ID == productID {
+			return &products[i]
+		}
+	}
+	return nil
+}
+
+// listProducts lists the catalog, leaving out archived products unless
+// includeArchived is set
+func listProducts(ctx context.Context, includeArchived bool) []Product {
+	_, span := startStoreSpan(ctx, "listProducts", attribute.Bool("include_archived", includeArchived))
+	defer span.End()
+
+	list := []Product{}
+	for _, product := range products {
+		if product.ArchivedAt == nil || includeArchived {
+			list = append(list, product)
+		}
+	}
+	return list
+}
+
+// archivedProducts lists the products that have been archived
+func archivedProducts(ctx context.Context) []Product {
+	_, span := startStoreSpan(ctx, "archivedProducts")
+	defer span.End()
+
+	list := []Product{}
+	for _, product := range products {
+		if product.ArchivedAt != nil {
+			list = append(list, product)
+		}
+	}
+	return list
+}
+
+// findCart looks up a user's cart, or returns nil if they don't have one
+func findCart(ctx context.Context, userID string) *Cart {
+	_, span := startStoreSpan(ctx, "findCart", attribute.String("user.id", userID))
+	defer span.End()
+
+	for i := range carts {
+		if carts[i].UserID == userID {
+			return &carts[i]
+		}
+	}
+	return nil
+}
+
+// findOrCreateCart looks up a user's cart, creating an empty one if needed
+func findOrCreateCart(ctx context.Context, userID string) *Cart {
+	cart := findCart(ctx, userID)
+	if cart != nil {
+		return cart
+	}
+
+	_, span := startStoreSpan(ctx, "createCart", attribute.String("user.id", userID))
+	defer span.End()
+
+	carts = append(carts, Cart{UserID: userID, Items: []CartItem{}})
+	return &carts[len(carts)-1]
+}
+
+// findUser looks up an account by ID
+func findUser(ctx context.Context, userID string) *User {
+	_, span := startStoreSpan(ctx, "findUser", attribute.String("user.id", userID))
+	defer span.End()
+
+	for i := range users {
+		if users[i].ID == userID {
+			return &users[i]
+		}
+	}
+	return nil
+}
+
+// findUserByEmail looks up an account by email address
+func findUserByEmail(ctx context.Context, email string) *User {
+	_, span := startStoreSpan(ctx, "findUserByEmail")
+	defer span.End()
+
+	for i := range users {
+		if users[i].Email == email {
+			return &users[i]
+		}
+	}
+	return nil
+}
+
+// saveUser adds a new account
+func saveUser(ctx context.Context, user User) {
+	_, span := startStoreSpan(ctx, "saveUser", attribute.String("user.id", user.ID))
+	defer span.End()
+
+	users = append(users, user)
+}
+
+// findOrder looks up an order by ID
+func findOrder(ctx context.Context, orderID string) *Order {
+	_, span := startStoreSpan(ctx, "findOrder", attribute.String("order.id", orderID))
+	defer span.End()
+
+	for i := range orders {
+		if orders[i].ID == orderID {
+			return &orders[i]
+		}
+	}
+	return nil
+}
+
+// userOrders lists a user's orders, oldest first
+func userOrders(ctx context.Context, userID string) []Order {
+	_, span := startStoreSpan(ctx, "userOrders", attribute.String("user.id", userID))
+	defer span.End()
+
+	var list []Order
+	for _, order := range orders {
+		if order.UserID == userID {
+			list = append(list, order)
+		}
+	}
+	return list
+}
+
+// saveOrder adds a new order
+func saveOrder(ctx context.Context, order Order) {
+	_, span := startStoreSpan(ctx, "saveOrder", attribute.String("order.id", order.ID))
+	defer span.End()
+
+	orders = append(orders, order)
+}
+
+// findReturn looks up a return by ID
+func findReturn(ctx context.Context, returnID string) *Return {
+	_, span := startStoreSpan(ctx, "findReturn", attribute.String("return.id", returnID))
+	defer span.End()
+
+	for i := range returns {
+		if returns[i].ID == returnID {
+			return &returns[i]
+		}
+	}
+	return nil
+}
+
+// findShipment looks up a shipment by ID
+func findShipment(ctx context.Context, shipmentID string) *Shipment {
+	_, span := startStoreSpan(ctx, "findShipment", attribute.String("shipment.id", shipmentID))
+	defer span.End()
+
+	for i := range shipments {
+		if shipments[i].ID == shipmentID {
+			return &shipments[i]
+		}
+	}
+	return nil
+}
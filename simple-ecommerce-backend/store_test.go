@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// keepSlice restores a package-level slice when a test ends
+func keepSlice[T any](t *testing.T, s *[]T) {
+	saved := append([]T(nil), (*s)...)
+	t.Cleanup(func() { *s = saved })
+}
+
+// keepValue restores a package-level variable when a test ends
+func keepValue[T any](t *testing.T, v *T) {
+	saved := *v
+	t.Cleanup(func() { *v = saved })
+}
+
+// keepStore restores the in-memory store when a test ends, so tests can
+// place orders and edit the catalog freely
+func keepStore(t *testing.T) {
+	keepSlice(t, &products)
+	keepValue(t, &lastProductNumber)
+	keepSlice(t, &inventoryLedger)
+	keepSlice(t, &carts)
+	keepSlice(t, &orders)
+	keepSlice(t, &priceRules)
+	keepSlice(t, &promotions)
+	keepSlice(t, &promotionUsages)
+	keepSlice(t, &returns)
+	keepSlice(t, &refunds)
+	keepSlice(t, &shipments)
+}
+
+// testAddress is a shipping address in California, taxed at 7.25%
+var testAddress = Address{Street: "1 Main St", City: "Sacramento", State: "CA", ZipCode: "95814", Country: "USA"}
+
+// placeOrder fills a user's cart and checks it out to testAddress
+func placeOrder(t *testing.T, userID string, items ...CartItem) Order {
+	t.Helper()
+	cart := findOrCreateCart(t.Context(), userID)
+	cart.Items = items
+
+	body, _ := json.Marshal(OrderRequest{ShippingAddr: testAddress, ShippingMethod: "express"})
+	w := httptest.NewRecorder()
+	createOrder(w, httptest.NewRequest("POST", "/orders/"+userID, strings.NewReader(string(body))), userID)
+	if w.Code != 201 {
+		t.Fatalf("checkout: status %d: %s", w.Code, w.Body)
+	}
+
+	var order Order
+	json.NewDecoder(w.Body).Decode(&order)
+	return order
+}
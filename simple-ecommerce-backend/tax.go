@@ -0,0 +1,189 @@
+package handler
+
+import (
+	_ "embed"
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Product tax categories
+const (
+	TaxStandard = "standard"
+	TaxReduced  = "reduced"
+	TaxExempt   = "exempt"
+)
+
+// TaxableLine is an amount to be taxed under a product tax category
+type TaxableLine struct {
+	ProductID string  `json:"productId"`
+	Category  string  `json:"category"`
+	Amount    float64 `json:"amount"` // Line total after discounts
+}
+
+// TaxLine represents one tax charged on an order
+type TaxLine struct {
+	Name   string  `json:"name"`
+	Rate   float64 `json:"rate"`
+	Amount float64 `json:"amount"`
+}
+
+// TaxCalculator works out the taxes due on a set of lines shipped to an address
+type TaxCalculator interface {
+	Calculate(addr Address, lines []TaxableLine) ([]TaxLine, error)
+}
+
+// TaxRate is a row in a tax table. Empty State, ZipPrefix and Category
+// match anything, so a country-wide rate and a city rate can both apply.
+type TaxRate struct {
+	Name      string  `json:"name"`
+	Country   string  `json:"country"`
+	State     string  `json:"state,omitempty"`
+	ZipPrefix string  `json:"zipPrefix,omitempty"`
+	Category  string  `json:"category,omitempty"`
+	Rate      float64 `json:"rate"` // e.g. 0.0725 for 7.25%
+}
+
+// TableTaxCalculator calculates taxes from a list of configured rates
+type TableTaxCalculator struct {
+	Rates []TaxRate `json:"rates"`
+}
+
+// defaultTaxRates is used when TAX_RATES_FILE is not set
+//
+//go:embed tax_rates.json
+var defaultTaxRates []byte
+
+var (
+	taxCalculator     TaxCalculator
+	taxCalculatorErr  error
+	taxCalculatorOnce sync.Once
+)
+
+// LoadTaxTable reads a tax table from a JSON file
+func LoadTaxTable(path string) (*TableTaxCalculator, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseTaxTable(data)
+}
+
+// parseTaxTable decodes a JSON tax table
+func parseTaxTable(data []byte) (*TableTaxCalculator, error) {
+	var table TableTaxCalculator
+	err := json.Unmarshal(data, &table)
+	if err != nil {
+		return nil, err
+	}
+	return &table, nil
+}
+
+// getTaxCalculator returns the tax calculator, loading the tax table on first use
+func getTaxCalculator() (TaxCalculator, error) {
+	taxCalculatorOnce.Do(func() {
+		if path := os.Getenv("TAX_RATES_FILE"); path != "" {
+			taxCalculator, taxCalculatorErr = LoadTaxTable(path)
+			return
+		}
+		taxCalculator, taxCalculatorErr = parseTaxTable(defaultTaxRates)
+	})
+	return taxCalculator, taxCalculatorErr
+}
+
+// matches reports whether a rate applies to an address and tax category
+func (rate TaxRate) matches(addr Address, category string) bool {
+	if !sameCountry(rate.Country, addr.Country) {
+		return false
+	}
+	if rate.State != "" && !strings.EqualFold(rate.State, addr.State) {
+		return false
+	}
+	if rate.ZipPrefix != "" && !strings.HasPrefix(addr.ZipCode, rate.ZipPrefix) {
+		return false
+	}
+	if rate.Category != "" && rate.Category != category {
+		return false
+	}
+	return true
+}
+
+// Calculate returns one tax line per applicable rate, summed over all lines
+func (t *TableTaxCalculator) Calculate(addr Address, lines []TaxableLine) ([]TaxLine, error) {
+	taxLines := []TaxLine{}
+
+	for _, rate := range t.Rates {
+		var amount float64
+		var applied bool
+		for _, line := range lines {
+			category := line.Category
+			if category == "" {
+				category = TaxStandard
+			}
+			if category == TaxExempt || !rate.matches(addr, category) {
+				continue
+			}
+			amount += line.Amount * rate.Rate
+			applied = true
+		}
+		if !applied {
+			continue
+		}
+
+		taxLines = append(taxLines, TaxLine{
+			Name:   rate.Name,
+			Rate:   rate.Rate,
+			Amount: roundCents(amount),
+		})
+	}
+
+	return taxLines, nil
+}
+
+// taxableLines spreads discounts over order lines to get the taxable amount
+// of each. Product-specific discounts reduce their own line; the rest are
+// shared out in proportion to line totals.
+func taxableLines(items []OrderItem, discounts []DiscountLine) []TaxableLine {
+	lines := make([]TaxableLine, len(items))
+	var subtotal float64
+	for i, item := range items {
+		lines[i] = TaxableLine{
+			ProductID: item.ProductID,
+			Category:  TaxStandard,
+			Amount:    item.Price * float64(item.Quantity),
+		}
+		for _, product := range products {
+			if product.ID == item.ProductID && product.TaxCategory != "" {
+				lines[i].Category = product.TaxCategory
+				break
+			}
+		}
+		subtotal += lines[i].Amount
+	}
+
+	var orderDiscount float64
+	for _, discount := range discounts {
+		if discount.ProductID == "" {
+			orderDiscount += discount.Amount
+			continue
+		}
+		for i := range lines {
+			if lines[i].ProductID == discount.ProductID {
+				lines[i].Amount -= discount.Amount
+				break
+			}
+		}
+	}
+
+	for i := range lines {
+		if subtotal > 0 {
+			lines[i].Amount -= orderDiscount * (items[i].Price * float64(items[i].Quantity)) / subtotal
+		}
+		if lines[i].Amount < 0 {
+			lines[i].Amount = 0
+		}
+	}
+
+	return lines
+}
@@ -0,0 +1,82 @@
+package handler
+
+import "testing"
+
+func TestTableTaxCalculator(t *testing.T) {
+	calculator, err := parseTaxTable(defaultTaxRates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := []TaxableLine{
+		{ProductID: "book", Category: TaxReduced, Amount: 20},
+		{ProductID: "gift", Category: TaxExempt, Amount: 50},
+		{ProductID: "tv", Amount: 100}, // No category is standard
+	}
+
+	tests := []struct {
+		name string
+		addr Address
+		want map[string]float64
+	}{
+		{"UK standard and reduced", Address{Country: "UK", ZipCode: "SW1A 1AA"},
+			map[string]float64{"UK VAT": 20, "UK VAT (reduced)": 1}},
+		{"California ignores categories", Address{Country: "US", State: "ca", ZipCode: "95814"},
+			map[string]float64{"California State Tax": 8.7}},
+		{"Los Angeles adds a county rate", Address{Country: "United States", State: "CA", ZipCode: "90012"},
+			map[string]float64{"California State Tax": 8.7, "Los Angeles County Tax": 2.7}},
+		{"no rates for the country", Address{Country: "Japan", ZipCode: "100-0001"},
+			map[string]float64{}},
+	}
+	for _, tc := range tests {
+		taxes, err := calculator.Calculate(tc.addr, lines)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		got := map[string]float64{}
+		for _, tax := range taxes {
+			got[tax.Name] = tax.Amount
+		}
+		if len(got) != len(tc.want) {
+			t.Errorf("%s: taxes %v, want %v", tc.name, got, tc.want)
+			continue
+		}
+		for name, amount := range tc.want {
+			if got[name] != amount {
+				t.Errorf("%s: %s = %v, want %v", tc.name, name, got[name], amount)
+			}
+		}
+	}
+}
+
+func TestTaxableLinesShareDiscounts(t *testing.T) {
+	keepStore(t)
+	for i := range products {
+		if products[i].ID == "p2" {
+			products[i].TaxCategory = TaxExempt
+		}
+	}
+	items := []OrderItem{
+		{ProductID: "p1", Price: 30, Quantity: 2},
+		{ProductID: "p2", Price: 40, Quantity: 1},
+	}
+	discounts := []DiscountLine{
+		{Code: "P2OFF", ProductID: "p2", Amount: 10},
+		{Code: "SAVE10", Amount: 10},
+	}
+
+	lines := taxableLines(items, discounts)
+	// The order discount splits 60:40 by line total
+	if lines[0].Amount != 54 || lines[0].Category != TaxStandard {
+		t.Errorf("p1 line %+v, want 54 standard", lines[0])
+	}
+	if lines[1].Amount != 26 || lines[1].Category != TaxExempt {
+		t.Errorf("p2 line %+v, want 26 exempt", lines[1])
+	}
+
+	// Discounts never take a line below zero
+	lines = taxableLines(items[1:], []DiscountLine{{ProductID: "p2", Amount: 100}})
+	if lines[0].Amount != 0 {
+		t.Errorf("over-discounted line is %v, want 0", lines[0].Amount)
+	}
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenLifetime is how long a login or registration token stays valid
+const tokenLifetime = 7 * 24 * time.Hour
+
+// tokenKey signs auth tokens. It is the configured token secret. Without
+// one it is random in development, so tokens stop working on restart, and
+// nil anywhere else or when the configuration is invalid, so no token
+// verifies.
+var (
+	tokenKey     []byte
+	tokenKeyOnce sync.Once
+)
+
+func getTokenKey() []byte {
+	tokenKeyOnce.Do(func() {
+		cfg, err := getConfig()
+		if err != nil {
+			return
+		}
+		if secret := cfg.Secrets.TokenSecret.Reveal(); secret != "" {
+			tokenKey = []byte(secret)
+			return
+		}
+		if cfg.Environment == "development" {
+			tokenKey = make([]byte, 32)
+			rand.Read(tokenKey)
+		}
+	})
+	return tokenKey
+}
+
+// signToken returns the signature of a token's payload, or "" when there
+// is no key to sign with
+func signToken(payload string) string {
+	key := getTokenKey()
+	if key == nil {
+		return ""
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// issueToken returns a bearer token for a user, of the form
+// "token-<userID>-<issued unix time>-<signature>"
+func issueToken(userID string, issued time.Time) string {
+	payload := "token-" + userID + "-" + strconv.FormatInt(issued.Unix(), 10)
+	return payload + "-" + signToken(payload)
+}
+
+// verifyToken checks a token's signature and age, and returns the user it
+// was issued to
+func verifyToken(token string, now time.Time) (string, bool) {
+	payload, signature, ok := cutLast(token, "-")
+	if !ok || signature == "" || !hmac.Equal([]byte(signature), []byte(signToken(payload))) {
+		return "", false
+	}
+	rest, ok := strings.CutPrefix(payload, "token-")
+	if !ok {
+		return "", false
+	}
+	userID, issuedText, ok := cutLast(rest, "-")
+	if !ok || userID == "" {
+		return "", false
+	}
+	issued, err := strconv.ParseInt(issuedText, 10, 64)
+	if err != nil || now.Sub(time.Unix(issued, 0)) > tokenLifetime {
+		return "", false
+	}
+	return userID, true
+}
+
+// cutLast splits s around the last sep
+func cutLast(s, sep string) (string, string, bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return "", "", false
+	}
+	return s[:i], s[i+len(sep):], true
+}
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVerifyToken(t *testing.T) {
+	issued := time.Unix(1700000000, 0)
+	token := issueToken("u1", issued)
+	flipped := token[:len(token)-1] + "0"
+	if token[len(token)-1] == '0' {
+		flipped = token[:len(token)-1] + "1"
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		now   time.Time
+		user  string
+	}{
+		{"valid", token, issued.Add(time.Hour), "u1"},
+		{"expired", token, issued.Add(tokenLifetime + time.Second), ""},
+		{"tampered user", "token-u2" + token[len("token-u1"):], issued, ""},
+		{"bad signature", flipped, issued, ""},
+		{"unsigned", "token-u1-1700000000", issued, ""},
+		{"empty", "", issued, ""},
+	}
+	for _, tc := range tests {
+		user, ok := verifyToken(tc.token, tc.now)
+		if user != tc.user || ok != (tc.user != "") {
+			t.Errorf("%s: verifyToken = %q, %v; want %q", tc.name, user, ok, tc.user)
+		}
+	}
+}
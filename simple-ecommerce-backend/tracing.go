@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"learn_go/tracing"
+)
+
+// tracer creates the store's spans. The exporter is configured from the
+// environment on the first request; see package tracing for the settings.
+var tracer = otel.Tracer("learn_go/simple-ecommerce-backend")
+
+var tracingOnce sync.Once
+
+// setupTracing installs the tracer provider once per process. Spans are
+// exported as they end, so there is nothing to flush on exit. A bad setting
+// is logged rather than failing requests.
+func setupTracing() {
+	tracingOnce.Do(func() {
+		_, err := tracing.Setup("simple-ecommerce-backend")
+		if err != nil {
+			logger.Error("tracing disabled", "error", err)
+		}
+	})
+}
+
+// startSpan starts a span for a handler function as a child of the request
+// span. Handlers replace r so anything they call joins the same trace:
+//
+//	r, span := startSpan(r, "createOrder")
+//	defer span.End()
+func startSpan(r *http.Request, name string, attrs ...attribute.KeyValue) (*http.Request, trace.Span) {
+	ctx, span := tracer.Start(r.Context(), name, trace.WithAttributes(attrs...))
+	return r.WithContext(ctx), span
+}
+
+// startStoreSpan starts a span for a call into the in-memory store
+func startStoreSpan(ctx context.Context, operation string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs,
+		attribute.String("db.system.name", "memory"),
+		attribute.String("db.operation.name", operation),
+	)
+	return tracer.Start(ctx, "store."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+}
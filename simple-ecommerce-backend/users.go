@@ -3,6 +3,7 @@ package handler
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -13,6 +14,10 @@ type User struct {
 	Email    string `json:"email"`
 	Name     string `json:"name"`
 	Password string `json:"-"` // Never return password in JSON
+	
+	// Staff may use the admin endpoints and create promotions. It can't be
+	// set through the API, so registering never makes a staff account.
+	Staff bool `json:"-"`
 }
 
 // UserResponse is what we return to clients
@@ -37,18 +42,42 @@ var users = []User{
 		Name:     "John Doe",
 		Password: "password123", // In production, use hashed passwords
 	},
+	{
+		ID:       "u2",
+		Email:    "staff@example.com",
+		Name:     "Store Staff",
+		Password: "staffpass123",
+		Staff:    true,
+	},
+}
+
+// userRoutes lists the routes Handler serves, for CORS preflights and metrics
+var userRoutes = routeTable{
+	"/users/login":               {"POST"},
+	"/users/register":            {"POST"},
+	"/users/{id}":                {"GET"},
+	"/users/{id}/addresses":      {"GET", "POST"},
+	"/users/{id}/addresses/{id}": {"GET", "PUT", "DELETE"},
 }
 
 // Handler processes user-related requests
 func Handler(w http.ResponseWriter, r *http.Request) {
-	// Set CORS headers
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-	
-	// Handle preflight requests
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
+	// Tag the request with an ID and log it when done
+	w, r, done, ok := beginRequest(w, r, userRoutes)
+	defer done()
+	
+	// Refuse to serve with an invalid configuration
+	if !ok {
+		return
+	}
+	
+	// Add CORS headers and answer preflight requests
+	if handleCORS(w, r, userRoutes) {
+		return
+	}
+
+	// Turn away clients over the rate limit
+	if rateLimited(w, r) {
 		return
 	}
 
@@ -71,6 +100,12 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
+	// Handle address book endpoint
+	if len(pathParts) > 3 && pathParts[2] != "" && pathParts[3] == "addresses" {
+		handleAddresses(w, r, pathParts[2], pathParts)
+		return
+	}
+	
 	// Handle user profile endpoint
 	if len(pathParts) > 2 && pathParts[2] != "" {
 		userID := pathParts[2]
@@ -84,6 +119,9 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 
 // handleLogin processes login requests
 func handleLogin(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "handleLogin")
+	defer span.End()
+	
 	if r.Method != "POST" {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
@@ -97,23 +135,23 @@ func handleLogin(w http.ResponseWriter, r *http.Request) {
 	}
 	
 	// Find user by email
-	var user *User
-	for i := range users {
-		if users[i].Email == loginReq.Email {
-			user = &users[i]
-			break
-		}
-	}
+	user := findUserByEmail(r.Context(), loginReq.Email)
 	
 	// User not found or password incorrect
 	if user == nil || user.Password != loginReq.Password {
+		logins.Inc("failed")
+		requestLogger(r).Warn("login failed", "login", loginReq)
 		w.WriteHeader(http.StatusUnauthorized)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid credentials"})
 		return
 	}
 	
-	// Generate a simple token (in production, use JWT)
-	token := "token-" + user.ID + "-" + string(time.Now().Unix())
+	logins.Inc("succeeded")
+	setRequestUser(r, user.ID)
+	requestLogger(r).Info("login succeeded", "user", *user)
+	
+	// Sign a token with the configured secret
+	token := issueToken(user.ID, time.Now())
 	
 	// Return user info with token
 	response := UserResponse{
@@ -128,6 +166,9 @@ func handleLogin(w http.ResponseWriter, r *http.Request) {
 
 // handleRegister processes registration requests
 func handleRegister(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "handleRegister")
+	defer span.End()
+	
 	if r.Method != "POST" {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
@@ -141,22 +182,22 @@ func handleRegister(w http.ResponseWriter, r *http.Request) {
 	}
 	
 	// Check if email already exists
-	for _, user := range users {
-		if user.Email == newUser.Email {
-			w.WriteHeader(http.StatusConflict)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Email already in use"})
-			return
-		}
+	if findUserByEmail(r.Context(), newUser.Email) != nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Email already in use"})
+		return
 	}
 	
 	// Generate a simple ID (in production, use UUID)
-	newUser.ID = "u" + string(len(users)+1)
+	newUser.ID = "u" + strconv.Itoa(len(users)+1)
 	
 	// Add to users
-	users = append(users, newUser)
+	saveUser(r.Context(), newUser)
+	setRequestUser(r, newUser.ID)
+	requestLogger(r).Info("user registered", "user", newUser)
 	
-	// Generate a simple token (in production, use JWT)
-	token := "token-" + newUser.ID + "-" + string(time.Now().Unix())
+	// Sign a token with the configured secret
+	token := issueToken(newUser.ID, time.Now())
 	
 	// Return user info with token
 	response := UserResponse{
@@ -175,13 +216,7 @@ func handleUserProfile(w http.ResponseWriter, r *http.Request, id string) {
 	// In a real app, verify authentication token here
 	
 	// Find user by ID
-	var user *User
-	for i := range users {
-		if users[i].ID == id {
-			user = &users[i]
-			break
-		}
-	}
+	user := findUser(r.Context(), id)
 	
 	// User not found
 	if user == nil {
@@ -0,0 +1,24 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestRegisterNumbersUsers(t *testing.T) {
+	keepSlice(t, &users)
+
+	for _, email := range []string{"new1@example.com", "new2@example.com"} {
+		want := "u" + strconv.Itoa(len(users)+1)
+		w := httptest.NewRecorder()
+		handleRegister(w, httptest.NewRequest("POST", "/users/register", strings.NewReader(`{"email":"`+email+`","password":"pw"}`)))
+		var registered UserResponse
+		json.NewDecoder(w.Body).Decode(&registered)
+		if w.Code != 201 || registered.ID != want {
+			t.Errorf("%s: status %d, ID %q; want 201, %q", email, w.Code, registered.ID, want)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"errors"
+	"net/mail"
+	"regexp"
+	"strings"
+)
+
+// countryAliases maps the ways customers write a country to its ISO code
+var countryAliases = map[string]string{
+	"us":             "US",
+	"usa":            "US",
+	"united states":  "US",
+	"ca":             "CA",
+	"canada":         "CA",
+	"mx":             "MX",
+	"mexico":         "MX",
+	"gb":             "GB",
+	"uk":             "GB",
+	"united kingdom": "GB",
+	"de":             "DE",
+	"germany":        "DE",
+	"fr":             "FR",
+	"france":         "FR",
+	"nl":             "NL",
+	"netherlands":    "NL",
+	"au":             "AU",
+	"australia":      "AU",
+	"in":             "IN",
+	"india":          "IN",
+	"jp":             "JP",
+	"japan":          "JP",
+	"id":             "ID",
+	"indonesia":      "ID",
+}
+
+// postalCodePatterns are plausible postal code formats by country
+var postalCodePatterns = map[string]*regexp.Regexp{
+	"US": regexp.MustCompile(`^\d{5}(-\d{4})?$`),
+	"CA": regexp.MustCompile(`^[A-Za-z]\d[A-Za-z] ?\d[A-Za-z]\d$`),
+	"MX": regexp.MustCompile(`^\d{5}$`),
+	"GB": regexp.MustCompile(`^[A-Za-z]{1,2}\d[A-Za-z\d]? ?\d[A-Za-z]{2}$`),
+	"DE": regexp.MustCompile(`^\d{5}$`),
+	"FR": regexp.MustCompile(`^\d{5}$`),
+	"NL": regexp.MustCompile(`^\d{4} ?[A-Za-z]{2}$`),
+	"AU": regexp.MustCompile(`^\d{4}$`),
+	"IN": regexp.MustCompile(`^\d{6}$`),
+	"JP": regexp.MustCompile(`^\d{3}-?\d{4}$`),
+	"ID": regexp.MustCompile(`^\d{5}$`),
+}
+
+// phoneRule describes national phone numbers for a country
+type phoneRule struct {
+	callingCode string
+	minDigits   int // National number length, without calling code or trunk 0
+	maxDigits   int
+}
+
+// phoneRules are plausible phone number lengths by country
+var phoneRules = map[string]phoneRule{
+	"US": {callingCode: "1", minDigits: 10, maxDigits: 10},
+	"CA": {callingCode: "1", minDigits: 10, maxDigits: 10},
+	"MX": {callingCode: "52", minDigits: 10, maxDigits: 10},
+	"GB": {callingCode: "44", minDigits: 9, maxDigits: 10},
+	"DE": {callingCode: "49", minDigits: 6, maxDigits: 13},
+	"FR": {callingCode: "33", minDigits: 9, maxDigits: 9},
+	"NL": {callingCode: "31", minDigits: 9, maxDigits: 9},
+	"AU": {callingCode: "61", minDigits: 9, maxDigits: 9},
+	"IN": {callingCode: "91", minDigits: 10, maxDigits: 10},
+	"JP": {callingCode: "81", minDigits: 9, maxDigits: 10},
+	"ID": {callingCode: "62", minDigits: 8, maxDigits: 12},
+}
+
+// phoneSeparators are stripped before checking a phone number
+var phoneSeparators = strings.NewReplacer(" ", "", "-", "", ".", "", "(", "", ")", "")
+
+// digitsOnly matches a string of digits
+var digitsOnly = regexp.MustCompile(`^\d+$`)
+
+// countryCode returns the ISO code for a country name, or "" if unknown
+func countryCode(country string) string {
+	return countryAliases[strings.ToLower(strings.TrimSpace(country))]
+}
+
+// sameCountry reports whether two country names or codes mean the same
+// country, so "US", "USA" and "United States" all match rates and shipping
+// zones written with any of them
+func sameCountry(a, b string) bool {
+	codeA, codeB := countryCode(a), countryCode(b)
+	if codeA != "" && codeB != "" {
+		return codeA == codeB
+	}
+	return strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b))
+}
+
+// validateAddress checks an address has the required parts and a
+// postal code that looks right for its country
+func validateAddress(addr Address) error {
+	if strings.TrimSpace(addr.Street) == "" || strings.TrimSpace(addr.City) == "" {
+		return errors.New("Street and city required")
+	}
+	if strings.TrimSpace(addr.Country) == "" {
+		return errors.New("Country required")
+	}
+
+	pattern, ok := postalCodePatterns[countryCode(addr.Country)]
+	if ok && !pattern.MatchString(strings.TrimSpace(addr.ZipCode)) {
+		return errors.New("Invalid postal code for " + addr.Country)
+	}
+
+	return nil
+}
+
+// validatePhone checks a phone number looks plausible for a country.
+// Numbers may be national or international (+ and calling code).
+func validatePhone(phone, country string) error {
+	number := phoneSeparators.Replace(strings.TrimSpace(phone))
+	international := strings.HasPrefix(number, "+")
+	number = strings.TrimPrefix(number, "+")
+	if !digitsOnly.MatchString(number) {
+		return errors.New("Phone number may only contain digits, spaces, dashes and parentheses")
+	}
+
+	rule, ok := phoneRules[countryCode(country)]
+	if !ok {
+		// E.164 allows up to 15 digits including the calling code
+		if len(number) < 7 || len(number) > 15 {
+			return errors.New("Invalid phone number")
+		}
+		return nil
+	}
+
+	if international {
+		if !strings.HasPrefix(number, rule.callingCode) {
+			return errors.New("Phone number is not a " + country + " number")
+		}
+		number = strings.TrimPrefix(number, rule.callingCode)
+	} else if rule.callingCode == "1" && len(number) == rule.maxDigits+1 {
+		// North American numbers are often written with a leading 1
+		number = strings.TrimPrefix(number, "1")
+	} else {
+		// Most other countries dial a trunk 0 before national numbers
+		number = strings.TrimPrefix(number, "0")
+	}
+
+	if len(number) < rule.minDigits || len(number) > rule.maxDigits {
+		return errors.New("Invalid phone number for " + country)
+	}
+
+	return nil
+}
+
+// validateEmail checks an email address is well formed
+func validateEmail(email string) error {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != strings.TrimSpace(email) {
+		return errors.New("Invalid email address")
+	}
+	return nil
+}
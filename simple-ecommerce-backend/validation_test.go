@@ -0,0 +1,102 @@
+package handler
+
+import "testing"
+
+func TestSameCountry(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"US", "USA", true},
+		{"united states", " US ", true},
+		{"UK", "United Kingdom", true},
+		{"US", "CA", false},
+		{"Narnia", "narnia", true},
+		{"Narnia", "US", false},
+		{"", "US", false},
+	}
+	for _, tc := range tests {
+		if got := sameCountry(tc.a, tc.b); got != tc.same {
+			t.Errorf("sameCountry(%q, %q) = %v, want %v", tc.a, tc.b, got, tc.same)
+		}
+	}
+}
+
+func TestValidateAddressPostalCodes(t *testing.T) {
+	tests := []struct {
+		country string
+		zipCode string
+		valid   bool
+	}{
+		{"USA", "95814", true},
+		{"USA", "95814-1234", true},
+		{"USA", "9581", false},
+		{"Canada", "K1A 0B1", true},
+		{"Canada", "K1A0B1", true},
+		{"Canada", "12345", false},
+		{"UK", "SW1A 1AA", true},
+		{"UK", "M1 1AE", true},
+		{"UK", "SW1A", false},
+		{"Netherlands", "1012 AB", true},
+		{"Netherlands", "1012", false},
+		{"Japan", "100-0001", true},
+		{"India", "110001", true},
+		{"India", "11000", false},
+		{"Narnia", "anything", true},
+	}
+	for _, tc := range tests {
+		addr := Address{Street: "1 Main St", City: "Town", ZipCode: tc.zipCode, Country: tc.country}
+		if err := validateAddress(addr); (err == nil) != tc.valid {
+			t.Errorf("%s %q: error %v, want valid = %v", tc.country, tc.zipCode, err, tc.valid)
+		}
+	}
+
+	if validateAddress(Address{City: "Town", Country: "USA", ZipCode: "95814"}) == nil {
+		t.Error("address without a street accepted")
+	}
+	if validateAddress(Address{Street: "1 Main St", City: "Town"}) == nil {
+		t.Error("address without a country accepted")
+	}
+}
+
+func TestValidatePhone(t *testing.T) {
+	tests := []struct {
+		phone   string
+		country string
+		valid   bool
+	}{
+		{"(916) 555-0123", "USA", true},
+		{"1-916-555-0123", "USA", true},
+		{"+1 916 555 0123", "USA", true},
+		{"+44 916 555 0123", "USA", false},
+		{"555-0123", "USA", false},
+		{"020 7946 0958", "UK", true},
+		{"+44 20 7946 0958", "UK", true},
+		{"06 12345678", "Netherlands", true},
+		{"+31 6 1234567", "Netherlands", false},
+		{"555 0123 ext 4", "USA", false},
+		{"+999 1234567", "Narnia", true},
+		{"12345", "Narnia", false},
+	}
+	for _, tc := range tests {
+		if err := validatePhone(tc.phone, tc.country); (err == nil) != tc.valid {
+			t.Errorf("%s %q: error %v, want valid = %v", tc.country, tc.phone, err, tc.valid)
+		}
+	}
+}
+
+func TestValidateEmail(t *testing.T) {
+	for email, valid := range map[string]bool{
+		"a@example.com":           true,
+		"a.b+tag@example.co.uk":   true,
+		"@":                       false,
+		"a@":                      false,
+		"not an email":            false,
+		"Ann <a@example.com>":     false,
+		"a@example.com, b@ex.com": false,
+	} {
+		if err := validateEmail(email); (err == nil) != valid {
+			t.Errorf("%q: error %v, want valid = %v", email, err, valid)
+		}
+	}
+}
@@ -0,0 +1,475 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// WishlistItem is a product saved for later
+type WishlistItem struct {
+	ProductID string    `json:"productId"`
+	AddedAt   time.Time `json:"addedAt"`
+}
+
+// Wishlist is one of a user's named lists of saved products
+type Wishlist struct {
+	ID         string         `json:"id"`
+	UserID     string         `json:"userId"`
+	Name       string         `json:"name"`
+	Items      []WishlistItem `json:"items"`
+	ShareToken string         `json:"shareToken,omitempty"` // Empty unless the list is shared
+	CreatedAt  time.Time      `json:"createdAt"`
+	UpdatedAt  time.Time      `json:"updatedAt"`
+}
+
+// WishlistRequest represents a request to create or rename a wishlist
+type WishlistRequest struct {
+	Name string `json:"name"`
+}
+
+// WishlistItemRequest represents a request to save a product to a wishlist
+type WishlistItemRequest struct {
+	ProductID string `json:"productId"`
+}
+
+// MoveToCartRequest represents a request to move a saved product to the cart
+type MoveToCartRequest struct {
+	Quantity int `json:"quantity"` // Defaults to 1
+}
+
+// SharedWishlistItem is a saved product as shown on a shared list
+type SharedWishlistItem struct {
+	ProductID string  `json:"productId"`
+	Name      string  `json:"name"`
+	Price     float64 `json:"price"`
+	ImageURL  string  `json:"imageUrl"`
+	InStock   bool    `json:"inStock"`
+}
+
+// SharedWishlist is the read-only view of a wishlist behind a share link
+type SharedWishlist struct {
+	Name      string               `json:"name"`
+	OwnerName string               `json:"ownerName"`
+	Items     []SharedWishlistItem `json:"items"`
+	UpdatedAt time.Time            `json:"updatedAt"`
+}
+
+// In-memory wishlist database for demo purposes
+var wishlists = []Wishlist{
+	{
+		ID:     "w1",
+		UserID: "u1",
+		Name:   "Desk upgrade",
+		Items: []WishlistItem{
+			{ProductID: "p3", AddedAt: time.Now().AddDate(0, 0, -3)},
+		},
+		CreatedAt: time.Now().AddDate(0, 0, -3),
+		UpdatedAt: time.Now().AddDate(0, 0, -3),
+	},
+}
+
+// nextWishlistID counts every list ever created so deleted IDs aren't reused
+var nextWishlistID = 2
+
+// newShareToken generates an unguessable token for a share link
+func newShareToken() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// wishlistRoutes lists the routes Handler serves, for CORS preflights and metrics
+var wishlistRoutes = routeTable{
+	"/wishlists/shared/{id}":               {"GET"},
+	"/wishlists/{id}":                      {"GET", "POST"},
+	"/wishlists/{id}/{id}":                 {"GET", "PUT", "DELETE"},
+	"/wishlists/{id}/{id}/items":           {"POST"},
+	"/wishlists/{id}/{id}/items/{id}":      {"DELETE"},
+	"/wishlists/{id}/{id}/items/{id}/move": {"POST"},
+	"/wishlists/{id}/{id}/share":           {"POST", "DELETE"},
+}
+
+// Handler processes wishlist requests
+func Handler(w http.ResponseWriter, r *http.Request) {
+	// Tag the request with an ID and log it when done
+	w, r, done, ok := beginRequest(w, r, wishlistRoutes)
+	defer done()
+
+	// Refuse to serve with an invalid configuration
+	if !ok {
+		return
+	}
+
+	// Add CORS headers and answer preflight requests
+	if handleCORS(w, r, wishlistRoutes) {
+		return
+	}
+
+	// Turn away clients over the rate limit
+	if rateLimited(w, r) {
+		return
+	}
+
+	// Set content type
+	w.Header().Set("Content-Type", "application/json")
+
+	// Extract path parts
+	path := r.URL.Path
+	pathParts := strings.Split(path, "/")
+
+	// Handle public share links
+	if len(pathParts) > 3 && pathParts[2] == "shared" {
+		getSharedWishlist(w, r, pathParts[3])
+		return
+	}
+
+	if len(pathParts) < 3 || pathParts[2] == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "User ID required"})
+		return
+	}
+
+	// In a real app, get userID from authentication token
+	userID := pathParts[2]
+
+	// Handle specific wishlist
+	if len(pathParts) > 3 && pathParts[3] != "" {
+		handleSingleWishlist(w, r, userID, pathParts)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		_, span := startSpan(r, "listWishlists", attribute.String("user.id", userID))
+		defer span.End()
+		list := []Wishlist{}
+		for _, wishlist := range wishlists {
+			if wishlist.UserID == userID {
+				list = append(list, wishlist)
+			}
+		}
+		json.NewEncoder(w).Encode(list)
+	case "POST":
+		createWishlist(w, r, userID)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// createWishlist creates a new named list for the user
+func createWishlist(w http.ResponseWriter, r *http.Request, userID string) {
+	r, span := startSpan(r, "createWishlist", attribute.String("user.id", userID))
+	defer span.End()
+
+	var req WishlistRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Name required"})
+		return
+	}
+
+	// Find user by ID
+	if findUser(r.Context(), userID) == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "User not found"})
+		return
+	}
+
+	// List names are unique per user
+	for _, wishlist := range wishlists {
+		if wishlist.UserID == userID && strings.EqualFold(wishlist.Name, name) {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{"error": "A wishlist with this name already exists"})
+			return
+		}
+	}
+
+	now := time.Now()
+	wishlist := Wishlist{
+		ID:        "w" + strconv.Itoa(nextWishlistID),
+		UserID:    userID,
+		Name:      name,
+		Items:     []WishlistItem{},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	nextWishlistID++
+	wishlists = append(wishlists, wishlist)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(wishlist)
+}
+
+// handleSingleWishlist processes requests for one of the user's lists
+func handleSingleWishlist(w http.ResponseWriter, r *http.Request, userID string, pathParts []string) {
+	r, span := startSpan(r, "handleSingleWishlist", attribute.String("user.id", userID))
+	defer span.End()
+
+	listID := pathParts[3]
+
+	// Find wishlist by ID
+	var wishlist *Wishlist
+	for i := range wishlists {
+		if wishlists[i].ID == listID {
+			wishlist = &wishlists[i]
+			break
+		}
+	}
+
+	// Wishlist not found
+	if wishlist == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Wishlist not found"})
+		return
+	}
+
+	// Verify wishlist belongs to user
+	if wishlist.UserID != userID {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Access denied"})
+		return
+	}
+
+	// Handle items endpoints
+	if len(pathParts) > 4 && pathParts[4] == "items" {
+		handleWishlistItems(w, r, wishlist, pathParts)
+		return
+	}
+
+	// Handle share link endpoint
+	if len(pathParts) > 4 && pathParts[4] == "share" {
+		handleWishlistShare(w, r, wishlist)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		json.NewEncoder(w).Encode(wishlist)
+	case "PUT":
+		var req WishlistRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		name := strings.TrimSpace(req.Name)
+		if name == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Name required"})
+			return
+		}
+		for _, other := range wishlists {
+			if other.UserID == userID && other.ID != listID && strings.EqualFold(other.Name, name) {
+				w.WriteHeader(http.StatusConflict)
+				json.NewEncoder(w).Encode(map[string]string{"error": "A wishlist with this name already exists"})
+				return
+			}
+		}
+		wishlist.Name = name
+		wishlist.UpdatedAt = time.Now()
+		json.NewEncoder(w).Encode(wishlist)
+	case "DELETE":
+		var newWishlists []Wishlist
+		for i := range wishlists {
+			if wishlists[i].ID != listID {
+				newWishlists = append(newWishlists, wishlists[i])
+			}
+		}
+		wishlists = newWishlists
+		json.NewEncoder(w).Encode(map[string]string{"message": "Wishlist deleted"})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handleWishlistItems adds, removes and moves products on a list
+func handleWishlistItems(w http.ResponseWriter, r *http.Request, wishlist *Wishlist, pathParts []string) {
+	// POST /wishlists/{user}/{list}/items - Save a product
+	if len(pathParts) < 6 || pathParts[5] == "" {
+		if r.Method != "POST" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req WishlistItemRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		// Find product by ID; archived products can't be saved
+		product := findProduct(r.Context(), req.ProductID)
+		if product == nil || product.ArchivedAt != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Product not found"})
+			return
+		}
+
+		// Saving a product twice is a no-op
+		for _, item := range wishlist.Items {
+			if item.ProductID == req.ProductID {
+				json.NewEncoder(w).Encode(wishlist)
+				return
+			}
+		}
+
+		wishlist.Items = append(wishlist.Items, WishlistItem{ProductID: req.ProductID, AddedAt: time.Now()})
+		wishlist.UpdatedAt = time.Now()
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(wishlist)
+		return
+	}
+
+	productID := pathParts[5]
+	index := -1
+	for i, item := range wishlist.Items {
+		if item.ProductID == productID {
+			index = i
+			break
+		}
+	}
+
+	// Item not found
+	if index < 0 {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Item not found in wishlist"})
+		return
+	}
+
+	// POST /wishlists/{user}/{list}/items/{product}/move - Move to cart
+	if len(pathParts) > 6 && pathParts[6] == "move" {
+		if r.Method != "POST" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		// An empty body moves a single unit
+		req := MoveToCartRequest{Quantity: 1}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil && err != io.EOF {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		// Same checks as adding to the cart directly
+		cart, err := addCartItem(r.Context(), wishlist.UserID, productID, req.Quantity)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		wishlist.Items = append(wishlist.Items[:index], wishlist.Items[index+1:]...)
+		wishlist.UpdatedAt = time.Now()
+
+		json.NewEncoder(w).Encode(cart)
+		return
+	}
+
+	// DELETE /wishlists/{user}/{list}/items/{product} - Remove a product
+	if r.Method != "DELETE" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	wishlist.Items = append(wishlist.Items[:index], wishlist.Items[index+1:]...)
+	wishlist.UpdatedAt = time.Now()
+
+	json.NewEncoder(w).Encode(wishlist)
+}
+
+// handleWishlistShare turns a list's share link on (POST) or off (DELETE).
+// Turning it on again issues a new token, so old links stop working.
+func handleWishlistShare(w http.ResponseWriter, r *http.Request, wishlist *Wishlist) {
+	switch r.Method {
+	case "POST":
+		token, err := newShareToken()
+		if err != nil {
+			requestLogger(r).Error("failed to create share link", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to create share link"})
+			return
+		}
+		wishlist.ShareToken = token
+	case "DELETE":
+		wishlist.ShareToken = ""
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	wishlist.UpdatedAt = time.Now()
+
+	json.NewEncoder(w).Encode(wishlist)
+}
+
+// getSharedWishlist returns the read-only view of a list by its share token
+func getSharedWishlist(w http.ResponseWriter, r *http.Request, token string) {
+	r, span := startSpan(r, "getSharedWishlist")
+	defer span.End()
+
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Find wishlist by share token
+	var wishlist *Wishlist
+	for i := range wishlists {
+		if token != "" && wishlists[i].ShareToken == token {
+			wishlist = &wishlists[i]
+			break
+		}
+	}
+
+	// Wishlist not found or no longer shared
+	if wishlist == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Wishlist not found"})
+		return
+	}
+
+	view := SharedWishlist{
+		Name:      wishlist.Name,
+		Items:     []SharedWishlistItem{},
+		UpdatedAt: wishlist.UpdatedAt,
+	}
+	if owner := findUser(r.Context(), wishlist.UserID); owner != nil {
+		view.OwnerName = owner.Name
+	}
+	for _, item := range wishlist.Items {
+		for _, product := range products {
+			if product.ID == item.ProductID {
+				view.Items = append(view.Items, SharedWishlistItem{
+					ProductID: product.ID,
+					Name:      product.Name,
+					Price:     effectivePrice(product, time.Now()),
+					ImageURL:  product.ImageURL,
+					InStock:   product.Stock > 0 && product.ArchivedAt == nil,
+				})
+				break
+			}
+		}
+	}
+
+	json.NewEncoder(w).Encode(view)
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMoveToCartGoesThroughAddCartItem(t *testing.T) {
+	keepStore(t)
+	archived := time.Now()
+	products = append(products, Product{ID: "old", Name: "Discontinued", Price: 5, ArchivedAt: &archived})
+	carts = []Cart{{UserID: "u1", Items: []CartItem{{ProductID: "p1", Quantity: 2}}}}
+	wishlist := &Wishlist{ID: "w9", UserID: "u1", Items: []WishlistItem{{ProductID: "p1"}, {ProductID: "old"}}}
+
+	move := func(productID, body string) int {
+		path := "/wishlists/u1/w9/items/" + productID + "/move"
+		w := httptest.NewRecorder()
+		handleWishlistItems(w, httptest.NewRequest("POST", path, strings.NewReader(body)), wishlist, strings.Split(path, "/"))
+		return w.Code
+	}
+
+	// Refused moves leave the item on the list
+	if code := move("p1", `{"quantity":0}`); code != 400 || len(wishlist.Items) != 2 {
+		t.Errorf("zero quantity: status %d, %d items left", code, len(wishlist.Items))
+	}
+	if code := move("old", ``); code != 400 || len(wishlist.Items) != 2 {
+		t.Errorf("archived product: status %d, %d items left", code, len(wishlist.Items))
+	}
+
+	// A move adds to what is already in the cart
+	if code := move("p1", `{"quantity":3}`); code != 200 {
+		t.Fatalf("move p1: status %d", code)
+	}
+	if cart := findCart(t.Context(), "u1"); len(cart.Items) != 1 || cart.Items[0].Quantity != 5 {
+		t.Errorf("cart after move %+v, want 5 of p1", cart.Items)
+	}
+	if len(wishlist.Items) != 1 || wishlist.Items[0].ProductID != "old" {
+		t.Errorf("wishlist after move %+v, want only the archived product", wishlist.Items)
+	}
+}
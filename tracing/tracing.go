@@ -0,0 +1,109 @@
+// Package tracing sets up OpenTelemetry tracing for the services from
+// environment variables, with exporters that work offline:
+//
+//	OTEL_TRACES_EXPORTER  none (default), stdout, or file
+//	OTEL_TRACES_FILE      where the file exporter appends spans (default traces.jsonl)
+//	OTEL_SERVICE_NAME     overrides the service name passed to Setup
+//	OTEL_TRACES_SAMPLER   standard sampler settings, read by the SDK
+//
+// Spans are written one JSON object per line as they end. Trace context is
+// read from and written to W3C traceparent headers.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// DefaultFile is where the file exporter writes unless OTEL_TRACES_FILE is set
+const DefaultFile = "traces.jsonl"
+
+// Setup installs the global tracer provider and traceparent propagator.
+// The returned function flushes and closes the exporter; call it on
+// shutdown. With no exporter configured, spans are still created so trace
+// IDs reach the logs and downstream calls, but nothing is written.
+func Setup(serviceName string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	exporter, closer, err := newExporter()
+	if err != nil {
+		return nil, err
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES take precedence over
+	// the name given here
+	res, err := resource.New(context.Background(),
+		resource.WithAttributes(attribute.String("service.name", serviceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		if closer != nil {
+			closer.Close()
+		}
+		return nil, err
+	}
+
+	options := []sdktrace.TracerProviderOption{sdktrace.WithResource(res)}
+	if exporter != nil {
+		// Spans are exported as they end rather than in batches, so nothing
+		// is lost if the process is frozen or killed between requests
+		options = append(options, sdktrace.WithSyncer(exporter))
+	}
+	provider := sdktrace.NewTracerProvider(options...)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			closeErr := closer.Close()
+			if err == nil {
+				err = closeErr
+			}
+		}
+		return err
+	}, nil
+}
+
+// newExporter builds the exporter named by OTEL_TRACES_EXPORTER. It
+// returns a nil exporter when tracing output is off, and a closer when
+// the exporter owns a file.
+func newExporter() (sdktrace.SpanExporter, io.Closer, error) {
+	switch name := strings.ToLower(os.Getenv("OTEL_TRACES_EXPORTER")); name {
+	case "", "none":
+		return nil, nil, nil
+	case "stdout", "console":
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		return exporter, nil, err
+	case "file":
+		path := os.Getenv("OTEL_TRACES_FILE")
+		if path == "" {
+			path = DefaultFile
+		}
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, err
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		return exporter, file, nil
+	default:
+		return nil, nil, fmt.Errorf("tracing: unknown OTEL_TRACES_EXPORTER %q, want none, stdout or file", name)
+	}
+}
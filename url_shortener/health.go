@@ -0,0 +1,23 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"learn_go/health"
+)
+
+// healthChecker decides readiness. Short URLs live in memory, so there is
+// no database to reach and no migrations to check.
+var healthChecker = newHealthChecker()
+
+func newHealthChecker() *health.Checker {
+	checker := health.NewChecker()
+	checker.AddCheck("urlStore", func(ctx context.Context) error {
+		if urlMap == nil {
+			return errors.New("URL store not initialized")
+		}
+		return nil
+	})
+	return checker
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// logLevel is info until main applies the configured level
+var logLevel = new(slog.LevelVar)
+
+// logger writes one JSON object per line to stdout
+var logger = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: logLevel}))
+
+// requestIDPattern limits incoming request IDs to something safe to log and echo
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// loggerKey is where the request's logger is kept in the gin context
+const loggerKey = "logger"
+
+// newRequestID generates a random request ID
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// requestLogging tags each request with an ID, taken from X-Request-ID when
+// the caller sent a usable one, and logs the outcome once it is served
+func requestLogging() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		requestID := c.GetHeader("X-Request-ID")
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = newRequestID()
+		}
+		c.Header("X-Request-ID", requestID)
+		requestLog := logger.With("requestId", requestID)
+		if spanContext := trace.SpanContextFromContext(c.Request.Context()); spanContext.HasTraceID() {
+			requestLog = requestLog.With("traceId", spanContext.TraceID().String())
+		}
+		c.Set(loggerKey, requestLog)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		if status >= 500 {
+			level = slog.LevelError
+		} else if status >= 400 {
+			level = slog.LevelWarn
+		}
+
+		// Unmatched paths have no route, so fall back to the raw path
+		route := c.FullPath()
+		if route == "" {
+			route = c.Request.URL.Path
+		}
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("route", route),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Int("bytes", max(c.Writer.Size(), 0)),
+			slog.Float64("latencyMs", float64(time.Since(start).Microseconds())/1000),
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("errors", c.Errors.String()))
+		}
+		requestLog.LogAttrs(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// requestLogger returns the logger for a request, tagged with its ID
+func requestLogger(c *gin.Context) *slog.Logger {
+	requestLog, ok := c.Value(loggerKey).(*slog.Logger)
+	if !ok {
+		return logger
+	}
+	return requestLog
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"learn_go/metrics"
+)
+
+// metricsRegistry holds everything served on /metrics
+var metricsRegistry = metrics.NewRegistry()
+
+var (
+	httpRequestDuration = metricsRegistry.NewHistogram(
+		"http_request_duration_seconds",
+		"Time taken to serve HTTP requests.",
+		nil, "method", "route", "status",
+	)
+	shortURLsCreated = metricsRegistry.NewCounter(
+		"shortener_urls_created_total",
+		"Short URLs created.",
+	)
+	redirectsServed = metricsRegistry.NewCounter(
+		"shortener_redirects_total",
+		"Redirects served from short URLs.",
+	)
+)
+
+// requestMetrics records how long each request took. Paths that match no
+// route share one label so scanners can't blow up the series count.
+func requestMetrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		httpRequestDuration.Observe(time.Since(start).Seconds(), c.Request.Method, route, strconv.Itoa(c.Writer.Status()))
+	}
+}
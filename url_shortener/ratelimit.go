@@ -0,0 +1,25 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"learn_go/ratelimit"
+)
+
+// requestRateLimit turns away clients over the configured rate with 429
+// Too Many Requests. Clients are told apart by the connection's address,
+// since X-Forwarded-For is whatever the client says it is.
+func requestRateLimit(limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		allowed, wait := limiter.Allow(c.RemoteIP())
+		if allowed {
+			return
+		}
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"message": "Too many requests"})
+	}
+}
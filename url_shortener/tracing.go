@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the shortener's spans. main sets up the exporter; see
+// package tracing for the settings.
+var tracer = otel.Tracer("learn_go/url_shortener")
+
+// requestTracing starts a server span for each request, continuing the
+// caller's trace when it sent a traceparent header
+func requestTracing() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		ctx, span := tracer.Start(ctx, c.Request.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", c.Request.Method),
+				attribute.String("http.route", route),
+				attribute.String("url.path", c.Request.URL.Path),
+			),
+		)
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
+
+// startSpan starts a span for a handler as a child of the request span
+func startSpan(c *gin.Context, name string) trace.Span {
+	ctx, span := tracer.Start(c.Request.Context(), name)
+	c.Request = c.Request.WithContext(ctx)
+	return span
+}
+
+// saveURL stores a short URL mapping
+func saveURL(ctx context.Context, shortURL, longURL string) {
+	_, span := tracer.Start(ctx, "store.saveURL", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("short_url", shortURL)))
+	defer span.End()
+
+	urlMap[shortURL] = longURL
+}
+
+// lookupURL finds the long URL behind a short one
+func lookupURL(ctx context.Context, shortURL string) (string, bool) {
+	_, span := tracer.Start(ctx, "store.lookupURL", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("short_url", shortURL)))
+	defer span.End()
+
+	longURL, ok := urlMap[shortURL]
+	span.SetAttributes(attribute.Bool("found", ok))
+	return longURL, ok
+}
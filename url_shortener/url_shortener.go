@@ -1,34 +1,104 @@
 package main
 import (
+	"context"
+	"errors"
+	"flag"
 	"net/http"
 	"math/rand"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 	"github.com/gin-gonic/gin"
+	"learn_go/config"
+	"learn_go/cors"
+	"learn_go/health"
+	"learn_go/ratelimit"
+	"learn_go/tracing"
 )
 
 var urlMap = make(map[string]string)
 func main() {
 	rand.Seed(time.Now().UnixNano())
-	r := gin.Default()
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		logger.Error("can't start", "error", err)
+		os.Exit(2)
+	}
+	logLevel.UnmarshalText([]byte(cfg.LogLevel))
+
+	shutdownTracing, err := tracing.Setup("url-shortener")
+	if err != nil {
+		logger.Error("tracing disabled", "error", err)
+	} else {
+		defer shutdownTracing(context.Background())
+	}
+	r := gin.New()
+	// Registered before the middleware so scrapes and probes aren't logged,
+	// measured or traced
+	r.GET("/metrics", gin.WrapH(metricsRegistry.Handler()))
+	r.GET("/healthz", gin.WrapH(healthChecker.LivenessHandler()))
+	r.GET("/readyz", gin.WrapH(healthChecker.ReadinessHandler()))
+	r.GET("/version", gin.WrapH(health.VersionHandler()))
+	r.Use(gin.Recovery(), requestTracing(), requestLogging(), requestMetrics(), requestCORS(cors.New(cfg.CORS)))
+	if cfg.RateLimit.Enabled {
+		r.Use(requestRateLimit(ratelimit.New(cfg.RateLimit.RequestsPerMinute, cfg.RateLimit.Burst)))
+	}
 	r.POST("/shorten", shortenURL)
 	r.GET("/:shortURL", redirectURL)
-	r.Run(":8080")
+	registerPreflights(r)
+
+	srv := &http.Server{Addr: cfg.ListenAddr, Handler: r}
+	logger.Info("listening", "addr", cfg.ListenAddr)
+	go func() {
+		err := srv.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("server failed", "error", err)
+			os.Exit(1)
+		}
+	}()
+
+	// On SIGTERM or Ctrl-C, fail readiness first so traffic drains away,
+	// then stop accepting connections and let in-flight requests finish
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	<-ctx.Done()
+	healthChecker.SetShuttingDown()
+	logger.Info("shutting down")
+	time.Sleep(cfg.Shutdown.Delay.Std())
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Shutdown.Timeout.Std())
+	defer cancel()
+	err = srv.Shutdown(shutdownCtx)
+	if err != nil {
+		logger.Error("shutdown incomplete", "error", err)
+	}
 }
 
 func shortenURL(c *gin.Context) {
+	span := startSpan(c, "shortenURL")
+	defer span.End()
 	longURL := c.PostForm("url")
 	shortURL := generateShortURL()
-	urlMap[shortURL] = longURL
+	saveURL(c.Request.Context(), shortURL, longURL)
+	shortURLsCreated.Inc()
+	requestLogger(c).Info("short URL created", "shortURL", shortURL)
 	c.JSON(http.StatusOK, gin.H{"shortURL": shortURL})
 }
 
 func redirectURL(c *gin.Context) {
+	span := startSpan(c, "redirectURL")
+	defer span.End()
 	shortURL := c.Param("shortURL")
-	longURL, ok := urlMap[shortURL]
+	longURL, ok := lookupURL(c.Request.Context(), shortURL)
 	if !ok {
 		c.JSON(http.StatusNotFound, gin.H{"message": "Short URL not found"})
 		return
 	}
+	redirectsServed.Inc()
 	c.Redirect(http.StatusMovedPermanently, longURL)
 }
 